@@ -17,13 +17,13 @@ to ensure consistent formatting.`,
 		rootPath := viper.GetString("root_path")
 
 		// Initialize application
-		application, err := app.NewApp()
+		application, err := app.NewApp(buildInfo())
 		if err != nil {
 			return fmt.Errorf("failed to initialize application: %w", err)
 		}
 
 		// Format files
-		if err := application.FormatFiles(rootPath); err != nil {
+		if err := application.FormatFiles(cmd.Context(), rootPath); err != nil {
 			return fmt.Errorf("format failed: %w", err)
 		}
 
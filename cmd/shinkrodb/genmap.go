@@ -18,13 +18,13 @@ the final mapping files used by shinkro.`,
 		rootPath := viper.GetString("root_path")
 
 		// Initialize application
-		application, err := app.NewApp()
+		application, err := app.NewApp(buildInfo())
 		if err != nil {
 			return fmt.Errorf("failed to initialize application: %w", err)
 		}
 
 		// Generate mappings
-		if err := application.GenerateMappings(rootPath); err != nil {
+		if err := application.GenerateMappings(cmd.Context(), rootPath); err != nil {
 			return fmt.Errorf("generate mappings failed: %w", err)
 		}
 
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+	"github.com/varoOP/shinkrodb/internal/database"
+	"github.com/varoOP/shinkrodb/internal/logger"
+)
+
+var historyCmd = &cobra.Command{
+	Use:   "history",
+	Short: "Show the ID change history of a MAL entry",
+	Long: `Show every AniDB and TMDB ID change recorded in the cache for a MAL ID,
+including when the change happened and where the new ID came from.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		malID, _ := cmd.Flags().GetInt("mal-id")
+		if malID <= 0 {
+			return fmt.Errorf("--mal-id is required")
+		}
+
+		log := logger.NewLogger()
+
+		// Database lives in the current directory (./), same as run.
+		// History only reads it, a missing database is not created.
+		if _, err := os.Stat(filepath.Join(".", "shinkrodb.db")); err != nil {
+			if os.IsNotExist(err) {
+				return fmt.Errorf("no shinkrodb.db in the current directory, run shinkrodb run first to record mapping history")
+			}
+			return fmt.Errorf("failed to stat database: %w", err)
+		}
+
+		db, err := database.NewReadOnlyDB(".", log)
+		if err != nil {
+			return fmt.Errorf("failed to open database: %w", err)
+		}
+		defer db.Close()
+
+		cacheRepo := database.NewCacheRepo(log, db)
+
+		history, err := cacheRepo.GetMappingHistory(cmd.Context(), malID)
+		if err != nil {
+			return fmt.Errorf("failed to get mapping history: %w", err)
+		}
+
+		if len(history) == 0 {
+			fmt.Printf("No mapping history recorded for MAL ID %d\n", malID)
+			return nil
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "CHANGED AT\tSOURCE\tOLD ID\tNEW ID\tPROVENANCE")
+		for _, entry := range history {
+			fmt.Fprintf(w, "%s\t%s\t%d\t%d\t%s\n", entry.ChangedAt, entry.Source, entry.OldID, entry.NewID, entry.Provenance)
+		}
+
+		return w.Flush()
+	},
+}
+
+func init() {
+	historyCmd.Flags().Int("mal-id", 0, "MAL ID to show the mapping history for")
+	rootCmd.AddCommand(historyCmd)
+}
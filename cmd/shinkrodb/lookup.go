@@ -0,0 +1,191 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"github.com/varoOP/shinkrodb/internal/app"
+	"github.com/varoOP/shinkrodb/internal/domain"
+)
+
+var lookupCmd = &cobra.Command{
+	Use:   "lookup <id>",
+	Short: "Show everything known about a single anime",
+	Long: `Show everything known about a single anime: titles, type, release date, all IDs
+and where they came from, master file overrides and whether dedupe removed it.
+
+The ID is a MAL ID by default, use --source to look up an AniDB, TVDB or TMDB ID.
+Every MAL entry mapped to the ID is shown. The cache, the output files, anime-list.xml
+and the master files are read; sources that do not exist yet are skipped.
+
+--refresh re-resolves the AniDB ID (with the configured resolvers), the TVDB ID and,
+for movies, the TMDB ID of each entry live and updates the cache. The output files
+pick up the new IDs on the next run.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		rootPath := viper.GetString("root_path")
+		sourceStr, _ := cmd.Flags().GetString("source")
+		refresh, _ := cmd.Flags().GetBool("refresh")
+
+		id, err := strconv.Atoi(args[0])
+		if err != nil || id <= 0 {
+			return fmt.Errorf("invalid id: %s (must be a positive number)", args[0])
+		}
+
+		source := domain.IDSource(sourceStr)
+		switch source {
+		case domain.IDSourceMAL, domain.IDSourceAniDB, domain.IDSourceTVDB, domain.IDSourceTMDB:
+		default:
+			return fmt.Errorf("invalid source: %s (must be 'mal', 'anidb', 'tvdb', or 'tmdb')", sourceStr)
+		}
+
+		// Initialize application
+		application, err := app.NewApp(buildInfo())
+		if err != nil {
+			return fmt.Errorf("failed to initialize application: %w", err)
+		}
+
+		results, err := application.Lookup(cmd.Context(), rootPath, source, id, refresh)
+		if err != nil {
+			return fmt.Errorf("lookup failed: %w", err)
+		}
+
+		if len(results) == 0 {
+			fmt.Printf("No entry found for %s ID %d\n", source, id)
+			return nil
+		}
+
+		for i, result := range results {
+			if i > 0 {
+				fmt.Println()
+			}
+			if err := printLookup(result); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	},
+}
+
+// printLookup prints a single lookup result as a field/value list
+func printLookup(r *domain.LookupResult) error {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+
+	fmt.Fprintf(w, "MAL ID\t%d\n", r.MalID)
+	if r.Anime != nil {
+		fmt.Fprintf(w, "Title\t%s\n", r.Anime.MainTitle)
+		if r.Anime.EnglishTitle != "" {
+			fmt.Fprintf(w, "English title\t%s\n", r.Anime.EnglishTitle)
+		}
+		fmt.Fprintf(w, "Type\t%s\n", r.Anime.Type)
+		fmt.Fprintf(w, "Release date\t%s\n", r.Anime.ReleaseDate)
+	}
+	if r.Cache != nil {
+		if r.Anime == nil {
+			fmt.Fprintf(w, "Type\t%s\n", r.Cache.Type)
+			fmt.Fprintf(w, "Release date\t%s\n", r.Cache.ReleaseDate)
+		}
+		fmt.Fprintf(w, "Status\t%s\n", r.Cache.Status)
+		fmt.Fprintf(w, "Cached at\t%s\n", r.Cache.CachedAt)
+		if r.Cache.TombstonedAt != "" {
+			fmt.Fprintf(w, "Tombstoned at\t%s\n", r.Cache.TombstonedAt)
+		}
+	}
+
+	// IDs as written to the output files, next to the cached IDs and their provenance
+	var anidbID, tvdbID, tmdbID int
+	if r.Anime != nil {
+		anidbID, tvdbID, tmdbID = r.Anime.AnidbID, r.Anime.TvdbID, r.Anime.TmdbID
+	}
+	fmt.Fprintf(w, "AniDB ID\t%s\n", formatLookupID(anidbID, r.CachedAniDBID, r.LatestProvenance(domain.MappingSourceAniDB)))
+	fmt.Fprintf(w, "TVDB ID\t%s\n", formatLookupID(tvdbID, 0, ""))
+	fmt.Fprintf(w, "TMDB ID\t%s\n", formatLookupID(tmdbID, r.CachedTMDBID, r.LatestProvenance(domain.MappingSourceTMDB)))
+	if r.ScrapeAttempt != nil {
+		fmt.Fprintf(w, "Last scrape\t%s (%d consecutive misses)\n", r.ScrapeAttempt.LastAttemptAt, r.ScrapeAttempt.Misses)
+	}
+
+	files := make([]string, 0, len(r.Files))
+	for _, file := range r.Files {
+		files = append(files, string(file))
+	}
+	fmt.Fprintf(w, "Output files\t%s\n", orNone(strings.Join(files, ", ")))
+	fmt.Fprintf(w, "Deduped\t%t\n", r.Deduped)
+
+	if r.AnimeList != nil {
+		fmt.Fprintf(w, "anime-list.xml\t%s (anidb %d, tvdb %d, season %s, tmdb %d)\n",
+			r.AnimeList.Name, r.AnimeList.AnidbID, r.AnimeList.TvdbID, orNone(r.AnimeList.DefaultTvdbSeason), r.AnimeList.TmdbID)
+	} else {
+		fmt.Fprintf(w, "anime-list.xml\t%s\n", orNone(""))
+	}
+
+	if m := r.TVDBMaster; m != nil {
+		fmt.Fprintf(w, "TVDB master\ttvdb %d, season %d, start %d, mapping %t (%d overrides)\n",
+			m.Tvdbid, m.TvdbSeason, m.Start, m.UseMapping, len(m.AnimeMapping))
+	}
+	if m := r.TMDBMaster; m != nil {
+		fmt.Fprintf(w, "TMDB master\ttmdb %d (%s)\n", m.TMDBID, m.MainTitle)
+	}
+
+	if rf := r.Refreshed; rf != nil {
+		fmt.Fprintf(w, "Refreshed AniDB ID\t%s\n", formatLookupID(rf.AnidbID, 0, rf.AnidbProvenance))
+		fmt.Fprintf(w, "Refreshed TVDB ID\t%s\n", formatLookupID(rf.TvdbID, 0, ""))
+		if r.Anime != nil && r.Anime.Type == "movie" {
+			fmt.Fprintf(w, "Refreshed TMDB ID\t%s\n", formatLookupID(rf.TmdbID, 0, rf.TmdbProvenance))
+		}
+	}
+
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	if len(r.History) > 0 {
+		fmt.Println()
+		w = tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "CHANGED AT\tSOURCE\tOLD ID\tNEW ID\tPROVENANCE")
+		for _, entry := range r.History {
+			fmt.Fprintf(w, "%s\t%s\t%d\t%d\t%s\n", entry.ChangedAt, entry.Source, entry.OldID, entry.NewID, entry.Provenance)
+		}
+		return w.Flush()
+	}
+
+	return nil
+}
+
+// formatLookupID formats an output file ID with the cached ID, if it differs, and its provenance
+func formatLookupID(id, cached int, provenance domain.Provenance) string {
+	if id == 0 {
+		id = cached
+	}
+	if id == 0 {
+		return orNone("")
+	}
+
+	s := strconv.Itoa(id)
+	if cached != 0 && cached != id {
+		s += fmt.Sprintf(" (cache: %d)", cached)
+	}
+	if provenance != "" {
+		s += fmt.Sprintf(" [%s]", provenance)
+	}
+	return s
+}
+
+// orNone returns s, or "-" if s is empty
+func orNone(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+func init() {
+	lookupCmd.Flags().String("source", string(domain.IDSourceMAL), "Kind of ID to look up: mal, anidb, tvdb, or tmdb")
+	lookupCmd.Flags().Bool("refresh", false, "Re-resolve the entry live and update the cache")
+	rootCmd.AddCommand(lookupCmd)
+}
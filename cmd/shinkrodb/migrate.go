@@ -39,7 +39,7 @@ After migration, you can use the new efficient cache system.`,
 			Msg("Starting cache migration")
 
 		// Initialize repository to get anime data
-		animeRepo := repository.NewFileRepository(log)
+		animeRepo := repository.NewFileRepository(log, nil)
 		paths := domain.NewPaths(rootPath)
 
 		// Only fetch MAL IDs if cache-dir is provided (needed for release dates/types in migration)
@@ -60,8 +60,8 @@ After migration, you can use the new efficient cache system.`,
 			}
 
 			// Fetch MAL IDs first (needed for release dates/types in migration)
-			malSvc := mal.NewService(log, cfg, animeRepo, paths.MalIDPath, paths.AniDBPath)
-			if err := malSvc.GetAnimeIDs(cmd.Context(), cacheRepo); err != nil {
+			malSvc := mal.NewService(log, cfg, animeRepo, nil, paths.MalIDPath, paths.AniDBPath)
+			if _, _, err := malSvc.GetAnimeIDs(cmd.Context(), cacheRepo); err != nil {
 				return fmt.Errorf("failed to get MAL IDs: %w", err)
 			}
 		}
@@ -1,12 +1,16 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"os"
+	"os/signal"
 	"path/filepath"
+	"syscall"
 
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
+	"github.com/varoOP/shinkrodb/internal/domain"
 )
 
 var (
@@ -25,11 +29,31 @@ by aggregating data from MyAnimeList, AniDB, TVDB, and TMDB.`,
 	Version: version,
 }
 
+// buildInfo returns the version information set at build time
+func buildInfo() domain.BuildInfo {
+	return domain.BuildInfo{
+		Version: version,
+		Commit:  commit,
+		Date:    date,
+	}
+}
+
 // Execute adds all child commands to the root command and sets flags appropriately.
 // This is called by main.main(). It only needs to happen once to the rootCmd.
+// SIGINT and SIGTERM cancel the command context so long runs can shut down cleanly.
 func Execute() {
-	err := rootCmd.Execute()
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	go func() {
+		<-ctx.Done()
+		// Restore default signal handling so a second signal kills the process immediately
+		stop()
+	}()
+
+	err := rootCmd.ExecuteContext(ctx)
 	if err != nil {
+		stop()
 		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 		os.Exit(1)
 	}
@@ -2,10 +2,17 @@ package main
 
 import (
 	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
 
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 	"github.com/varoOP/shinkrodb/internal/app"
+	"github.com/varoOP/shinkrodb/internal/domain"
+	"github.com/varoOP/shinkrodb/internal/pipeline"
 )
 
 var runCmd = &cobra.Command{
@@ -20,10 +27,29 @@ var runCmd = &cobra.Command{
 6. Creates TVDB mapping files
 
 Fetch modes can be configured via --anidb and --tmdb flags or anidb_mode/tmdb_mode in config:
-  - default: Default behavior (AniDB: only scrape for MAL IDs without AniDB ID, released in past 1 year, type = "tv"; TMDB: only fetch for movies without TMDB ID)
+  - default: Default behavior (AniDB: only scrape for MAL IDs without AniDB ID that are due according to
+    the scrape policy (airing status, start date, type and previous misses), of any media type and start date;
+    TMDB: only fetch for movies without TMDB ID)
   - missing: Fetch all entries without ID (no filters)
   - all: Fetch everything, even if already has ID in cache
-  - skip: Skip fetching entirely`,
+  - skip: Skip fetching entirely
+
+Stages (mal, anidb, tvdb, tmdb, dedupe) can be selected with --stages, --from and --until.
+Stages that are not run reuse the intermediate lists of a previous run (JSON files,
+or shinkrodb.db tables with anime_store = "sqlite"), e.g.
+--stages=tvdb,tmdb,dedupe re-applies master file edits without fetching from MAL.
+
+--dry-run reports the entries each stage would fetch (by type and year), estimated
+request counts and durations, and which output files would change, without making
+any requests or writing files.
+
+--offline uses the cached anime-list.xml and animetitles.xml whatever their age instead of
+downloading them, and fails if they were never cached. The MAL and TMDB APIs and the scraper
+still need the network, combine it with --stages or the skip fetch modes.
+
+AniDB scrapes in default mode are capped per run by max_scrapes in config (default 500, 0 = unlimited),
+missing and all modes scrape every candidate. Scraper concurrency, delays,
+timeouts and retries are set with the scrape_* options in config.`,
 	RunE: func(cmd *cobra.Command, args []string) error {
 		rootPath := viper.GetString("root_path")
 
@@ -37,14 +63,40 @@ Fetch modes can be configured via --anidb and --tmdb flags or anidb_mode/tmdb_mo
 			viper.Set("tmdb_mode", tmdbMode)
 		}
 
+		// Only use cached copies of the remote files
+		if offline, _ := cmd.Flags().GetBool("offline"); offline {
+			viper.Set("offline", true)
+		}
+
+		// Select the pipeline stages to run, names are validated by the pipeline
+		selection := domain.StageSelection{}
+		stageNames, _ := cmd.Flags().GetStringSlice("stages")
+		for _, name := range stageNames {
+			selection.Only = append(selection.Only, domain.Stage(name))
+		}
+		from, _ := cmd.Flags().GetString("from")
+		selection.From = domain.Stage(from)
+		until, _ := cmd.Flags().GetString("until")
+		selection.Until = domain.Stage(until)
+
 		// Initialize application
-		application, err := app.NewApp()
+		application, err := app.NewApp(buildInfo())
 		if err != nil {
 			return fmt.Errorf("failed to initialize application: %w", err)
 		}
 
+		// Report what a run would do without fetching or writing anything
+		if dryRun, _ := cmd.Flags().GetBool("dry-run"); dryRun {
+			plans, err := application.DryRun(cmd.Context(), rootPath, selection)
+			if err != nil {
+				return fmt.Errorf("dry run failed: %w", err)
+			}
+
+			return printPlans(plans)
+		}
+
 		// Run the update process
-		if err := application.Run(rootPath); err != nil {
+		if err := application.Run(cmd.Context(), rootPath, selection); err != nil {
 			return fmt.Errorf("run failed: %w", err)
 		}
 
@@ -53,8 +105,77 @@ Fetch modes can be configured via --anidb and --tmdb flags or anidb_mode/tmdb_mo
 }
 
 func init() {
-	runCmd.Flags().String("anidb", "", "AniDB fetch mode: 'default' (past year, tv only), 'missing' (all without AniDB ID), 'all' (everything), or 'skip' (skip fetching)")
+	runCmd.Flags().String("anidb", "", "AniDB fetch mode: 'default' (due entries by scrape policy), 'missing' (all without AniDB ID), 'all' (everything), or 'skip' (skip fetching)")
 	runCmd.Flags().String("tmdb", "", "TMDB fetch mode: 'default' (only movies without TMDB ID), 'missing' (all movies without TMDB ID), 'all' (everything), or 'skip' (skip fetching)")
+	runCmd.Flags().StringSlice("stages", nil, "comma-separated stages to run: mal, anidb, tvdb, tmdb, dedupe (default: all)")
+	runCmd.Flags().String("from", "", "first stage to run")
+	runCmd.Flags().String("until", "", "last stage to run")
+	runCmd.Flags().Bool("offline", false, "only use the cached anime-list.xml and animetitles.xml, fail if they are not cached")
+	runCmd.Flags().Bool("dry-run", false, "report what would be fetched and which output files would change, without making requests or writing files")
 	rootCmd.AddCommand(runCmd)
 }
 
+
+// printPlans prints a dry run report
+func printPlans(plans []*pipeline.StagePlan) error {
+	fmt.Println("Dry run: nothing was fetched or written.")
+	fmt.Println()
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "STAGE\tCANDIDATES\tTO FETCH\tREQUESTS\tEST. DURATION\tNOTE")
+	for _, plan := range plans {
+		if plan.Fetch == nil {
+			fmt.Fprintf(w, "%s\t-\t-\t-\t-\t%s\n", plan.Stage, plan.Note)
+			continue
+		}
+		fmt.Fprintf(w, "%s\t%d\t%d\t%d\t%s\t%s\n", plan.Stage, plan.Fetch.Candidates, len(plan.Fetch.ToFetch),
+			plan.Fetch.Requests, plan.Fetch.Duration.Round(time.Second), plan.Fetch.Note)
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	for _, plan := range plans {
+		if plan.Fetch == nil || len(plan.Fetch.ToFetch) == 0 {
+			continue
+		}
+
+		fmt.Println()
+		fmt.Printf("%s to fetch by type: %s\n", plan.Stage, formatCounts(plan.Fetch.CountByType(), false))
+		fmt.Printf("%s to fetch by year: %s\n", plan.Stage, formatCounts(plan.Fetch.CountByYear(), true))
+	}
+
+	fmt.Println()
+	w = tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "OUTPUT\tCHANGE")
+	for _, plan := range plans {
+		for _, output := range plan.Outputs {
+			fmt.Fprintf(w, "%s\t%s\n", output.Path, output.Change)
+		}
+	}
+
+	return w.Flush()
+}
+
+// formatCounts formats counts as "key=count" pairs, ordered by key (descending)
+// or by count (highest first)
+func formatCounts(counts map[string]int, byKey bool) string {
+	keys := make([]string, 0, len(counts))
+	for key := range counts {
+		keys = append(keys, key)
+	}
+
+	sort.Slice(keys, func(i, j int) bool {
+		if byKey || counts[keys[i]] == counts[keys[j]] {
+			return keys[i] > keys[j]
+		}
+		return counts[keys[i]] > counts[keys[j]]
+	})
+
+	pairs := make([]string, 0, len(keys))
+	for _, key := range keys {
+		pairs = append(pairs, fmt.Sprintf("%s=%d", key, counts[key]))
+	}
+
+	return strings.Join(pairs, ", ")
+}
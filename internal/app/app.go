@@ -2,7 +2,9 @@ package app
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"os"
 	"path/filepath"
 
 	"github.com/rs/zerolog"
@@ -11,9 +13,13 @@ import (
 	"github.com/varoOP/shinkrodb/internal/dedupe"
 	"github.com/varoOP/shinkrodb/internal/domain"
 	"github.com/varoOP/shinkrodb/internal/format"
+	"github.com/varoOP/shinkrodb/internal/index"
 	"github.com/varoOP/shinkrodb/internal/logger"
 	"github.com/varoOP/shinkrodb/internal/mal"
+	"github.com/varoOP/shinkrodb/internal/manifest"
 	"github.com/varoOP/shinkrodb/internal/notification"
+	"github.com/varoOP/shinkrodb/internal/pipeline"
+	"github.com/varoOP/shinkrodb/internal/publish"
 	"github.com/varoOP/shinkrodb/internal/repository"
 	"github.com/varoOP/shinkrodb/internal/tmdb"
 	"github.com/varoOP/shinkrodb/internal/tvdb"
@@ -22,19 +28,29 @@ import (
 // App represents the main application with all dependencies initialized
 type App struct {
 	log             zerolog.Logger
+	build           domain.BuildInfo
 	config          *domain.Config
 	paths           *domain.Paths
 	animeRepo       domain.AnimeRepository
+	fileRepo        domain.AnimeRepository
 	mappingRepo     domain.MappingRepository
+	indexRepo       domain.IndexRepository
+	manifestRepo    domain.ManifestRepository
+	releaseRepo     domain.ReleaseRepository
+	dedupeReportRepo domain.DedupeReportRepository
 	malService      mal.Service
 	tmdbService     tmdb.Service
 	tvdbService     tvdb.Service
 	dedupeService   dedupe.Service
+	indexService    index.Service
+	manifestService manifest.Service
+	publishService  publish.Service
 	notificationService domain.NotificationService
 }
 
-// NewApp creates a new application instance with all dependencies initialized
-func NewApp() (*App, error) {
+// NewApp creates a new application instance with all dependencies initialized.
+// build identifies the shinkrodb build in the output manifest.
+func NewApp(build domain.BuildInfo) (*App, error) {
 	// Initialize logger
 	log := logger.NewLogger()
 
@@ -48,52 +64,66 @@ func NewApp() (*App, error) {
 	paths := domain.NewPaths(".")
 
 	// Initialize repositories
-	fileRepo := repository.NewFileRepository(log)
+	fileRepo := repository.NewFileRepository(log, cfg.OutputFormats)
 	var animeRepo domain.AnimeRepository = fileRepo
 	var mappingRepo domain.MappingRepository = fileRepo
+	var indexRepo domain.IndexRepository = fileRepo
+	var manifestRepo domain.ManifestRepository = fileRepo
+	var releaseRepo domain.ReleaseRepository = fileRepo
+	var dedupeReportRepo domain.DedupeReportRepository = fileRepo
 
 	// Initialize services
-	malService := mal.NewService(log, cfg, animeRepo, paths.MalIDPath, paths.AniDBPath)
-	tmdbService := tmdb.NewService(log, cfg, animeRepo, mappingRepo, paths)
-	tvdbService := tvdb.NewService(log, animeRepo, mappingRepo, paths)
-	dedupeService := dedupe.NewService(log, animeRepo)
 	notificationService := notification.NewService(log, cfg.DiscordWebhookURL)
+	malService := mal.NewService(log, cfg, animeRepo, notificationService, paths.MalIDPath, paths.AniDBPath)
+	tmdbService := tmdb.NewService(log, cfg, animeRepo, mappingRepo, paths.TVDBPath)
+	tvdbService := tvdb.NewService(log, cfg, mappingRepo)
+	dedupeService := dedupe.NewService(log, cfg, animeRepo, dedupeReportRepo, paths.DupesJSONPath, paths.DupesYAMLPath)
+	indexService := index.NewService(log, cfg, indexRepo, mappingRepo, paths.AniDBIndexPath, paths.TVDBIndexPath, paths.TMDBIndexPath)
+	manifestService := manifest.NewService(log, build, manifestRepo, paths.ManifestPath, outputFiles(paths, cfg))
+	publishService := publish.NewService(log, releaseRepo, string(paths.ShinkroPath))
 
 	return &App{
 		log:                log,
+		build:              build,
 		config:             cfg,
 		paths:              paths,
 		animeRepo:          animeRepo,
+		fileRepo:           fileRepo,
 		mappingRepo:        mappingRepo,
+		indexRepo:          indexRepo,
+		manifestRepo:       manifestRepo,
+		releaseRepo:        releaseRepo,
+		dedupeReportRepo:   dedupeReportRepo,
 		malService:         malService,
 		tmdbService:        tmdbService,
 		tvdbService:        tvdbService,
 		dedupeService:      dedupeService,
+		indexService:       indexService,
+		manifestService:    manifestService,
+		publishService:     publishService,
 		notificationService: notificationService,
 	}, nil
 }
 
-// Run executes the full database update process
-func (a *App) Run(rootPath string) (err error) {
-	ctx := context.Background()
-	
+// Run executes the database update process.
+// Only the stages chosen by selection are run, the others reuse the files of a previous run.
+// Cancelling ctx stops the run between writes; cache writes in flight are finished
+// and output files that were not completely built are discarded.
+func (a *App) Run(ctx context.Context, rootPath string, selection domain.StageSelection) (err error) {
 	// Send error notification if run fails
 	defer func() {
 		if err != nil {
-			if notifyErr := a.notificationService.SendError(ctx, err); notifyErr != nil {
+			if errors.Is(err, context.Canceled) {
+				a.log.Warn().Msg("Run interrupted, unfinished output files were discarded")
+				return
+			}
+			// The run context may already be done, the notification still has to go out
+			if notifyErr := a.notificationService.SendError(context.WithoutCancel(ctx), err); notifyErr != nil {
 				a.log.Warn().Err(notifyErr).Msg("Failed to send error notification")
 			}
 		}
 	}()
 
-	// Update paths with actual root path
-	a.paths = domain.NewPaths(rootPath)
-
-	// Update services with new paths
-	a.malService = mal.NewService(a.log, a.config, a.animeRepo, a.paths.MalIDPath, a.paths.AniDBPath)
-	a.tmdbService = tmdb.NewService(a.log, a.config, a.animeRepo, a.mappingRepo, a.paths)
-	a.tvdbService = tvdb.NewService(a.log, a.animeRepo, a.mappingRepo, a.paths)
-
 	// Initialize database and cache repository
 	// Store database in current directory (./) instead of root-path
 	db, err := database.NewDB(".", a.log)
@@ -102,48 +132,41 @@ func (a *App) Run(rootPath string) (err error) {
 	}
 	defer db.Close()
 
-	cacheRepo := database.NewCacheRepo(a.log, db)
+	a.useAnimeStore(db)
+	a.setRootPath(rootPath)
 
-	// Get MAL IDs and update mal_cache
-	if err := a.malService.GetAnimeIDs(ctx, cacheRepo); err != nil {
-		return fmt.Errorf("failed to get MAL IDs: %w", err)
-	}
-
-	// Scrape MAL for AniDB IDs (cache invalidation happens implicitly - only entries < 1 year old are used)
-	if err := a.malService.ScrapeAniDBIDs(ctx, cacheRepo); err != nil {
-		return fmt.Errorf("failed to scrape MAL: %w", err)
-	}
-
-	// Get TVDB IDs and update mapping
-	if err := a.tvdbService.GetTvdbIDs(ctx, rootPath); err != nil {
-		return fmt.Errorf("failed to get TVDB IDs: %w", err)
-	}
+	cacheRepo := database.NewCacheRepo(a.log, db)
 
-	// Get TMDB IDs
-	if err := a.tmdbService.GetTmdbIds(ctx, rootPath, cacheRepo); err != nil {
-		return fmt.Errorf("failed to get TMDB IDs: %w", err)
+	// Build the pipeline and select the stages to run
+	runner, err := pipeline.NewRunner(a.log, a.animeRepo, a.stages(rootPath, cacheRepo)...)
+	if err != nil {
+		return fmt.Errorf("failed to build pipeline: %w", err)
 	}
 
-	// Check for duplicates
-	animeList, err := a.animeRepo.Get(ctx, a.paths.TMDBPath)
+	stages, err := runner.Select(selection)
 	if err != nil {
-		return fmt.Errorf("failed to get anime list: %w", err)
+		return err
 	}
 
-	dupeCount, deduped, err := a.dedupeService.CheckDupes(ctx, animeList)
+	// Stages that are not selected reuse the intermediate files of a previous run
+	results, err := runner.Run(ctx, stages)
 	if err != nil {
-		return fmt.Errorf("failed to check dupes: %w", err)
+		return err
 	}
 
-	a.log.Info().Int("dupe_count", dupeCount).Msg("Duplicate check complete")
-
-	// Store deduped list
-	if err := a.animeRepo.Store(ctx, a.paths.ShinkroPath, deduped); err != nil {
-		return fmt.Errorf("failed to store deduped anime: %w", err)
+	// Statistics are calculated from the output of the last stage that ran
+	final, err := a.animeRepo.Get(ctx, runner.Stage(stages[len(stages)-1]).Outputs()[0])
+	if err != nil {
+		return fmt.Errorf("failed to get final anime list: %w", err)
 	}
 
 	// Calculate and log final statistics
-	stats := calculateStatistics(deduped, dupeCount)
+	malResult := results.Get(domain.StageMAL)
+	stats := calculateStatistics(final, results.Get(domain.StageDedupe).Get("dupes"))
+	stats.TombstonedMALIDs = malResult.Get("tombstoned")
+	stats.PendingMALIDs = malResult.Get("pending")
+	stats.RestoredMALIDs = malResult.Get("restored")
+	stats.PurgedMALIDs = malResult.Get("purged")
 	a.log.Info().
 		Int("total_mal_ids", stats.TotalMALIDs).
 		Int("mal_ids_with_anidb", stats.MALIDsWithAniDB).
@@ -157,6 +180,10 @@ func (a *App) Run(rootPath string) (err error) {
 		Float64("anidb_coverage_pct", stats.AniDBCoveragePercent).
 		Float64("tmdb_coverage_pct", stats.TMDBCoveragePercent).
 		Float64("tvdb_coverage_pct", stats.TVDBCoveragePercent).
+		Int("tombstoned_mal_ids", stats.TombstonedMALIDs).
+		Int("pending_mal_ids", stats.PendingMALIDs).
+		Int("restored_mal_ids", stats.RestoredMALIDs).
+		Int("purged_mal_ids", stats.PurgedMALIDs).
 		Msg("=== FINAL STATISTICS ===")
 
 	// Send success notification
@@ -167,6 +194,79 @@ func (a *App) Run(rootPath string) (err error) {
 	return nil
 }
 
+// useAnimeStore switches the anime lists to the tables of db if the sqlite anime store is configured.
+// It has to be called before setRootPath, which hands the repository to the services.
+func (a *App) useAnimeStore(db *database.DB) {
+	if a.config.AnimeStore != domain.AnimeStoreSQLite {
+		return
+	}
+
+	a.animeRepo = database.NewAnimeRepo(a.log, db, a.fileRepo, a.config.JSONExports)
+}
+
+// setRootPath updates the paths and the services using them with the actual root path
+func (a *App) setRootPath(rootPath string) {
+	a.paths = domain.NewPaths(rootPath)
+
+	a.malService = mal.NewService(a.log, a.config, a.animeRepo, a.notificationService, a.paths.MalIDPath, a.paths.AniDBPath)
+	a.tmdbService = tmdb.NewService(a.log, a.config, a.animeRepo, a.mappingRepo, a.paths.TVDBPath)
+	a.tvdbService = tvdb.NewService(a.log, a.config, a.mappingRepo)
+	a.dedupeService = dedupe.NewService(a.log, a.config, a.animeRepo, a.dedupeReportRepo, a.paths.DupesJSONPath, a.paths.DupesYAMLPath)
+	a.indexService = index.NewService(a.log, a.config, a.indexRepo, a.mappingRepo, a.paths.AniDBIndexPath, a.paths.TVDBIndexPath, a.paths.TMDBIndexPath)
+	a.manifestService = manifest.NewService(a.log, a.build, a.manifestRepo, a.paths.ManifestPath, outputFiles(a.paths, a.config))
+	a.publishService = publish.NewService(a.log, a.releaseRepo, string(a.paths.ShinkroPath))
+}
+
+// outputFiles returns the output files described by the manifest, including the configured exports
+// and the compressed variants and delta of the published shinkro output
+func outputFiles(paths *domain.Paths, cfg *domain.Config) []string {
+	files := paths.OutputFiles()
+	for _, file := range domain.AnimeFiles {
+		for _, format := range cfg.OutputFormats[file] {
+			files = append(files, domain.ExportPath(filepath.Join(paths.RootDir, string(file)), format))
+		}
+	}
+
+	for _, compression := range domain.Compressions {
+		files = append(files, domain.CompressedPath(string(paths.ShinkroPath), compression))
+	}
+	files = append(files, domain.DeltaPath(string(paths.ShinkroPath)))
+
+	return files
+}
+
+// DryRun reports what Run would fetch and which output files would change.
+// No scrape or search requests are made and no files are written; the cache database
+// is opened read-only and the files of the previous run are used as stage inputs.
+func (a *App) DryRun(ctx context.Context, rootPath string, selection domain.StageSelection) ([]*pipeline.StagePlan, error) {
+	// Without a cache database every entry counts as uncached
+	var cacheRepo domain.CacheRepo
+	if _, err := os.Stat(filepath.Join(".", "shinkrodb.db")); err == nil {
+		db, err := database.NewReadOnlyDB(".", a.log)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open database: %w", err)
+		}
+		defer db.Close()
+
+		a.useAnimeStore(db)
+		cacheRepo = database.NewCacheRepo(a.log, db)
+	}
+
+	a.setRootPath(rootPath)
+
+	runner, err := pipeline.NewRunner(a.log, a.animeRepo, a.stages(rootPath, cacheRepo)...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build pipeline: %w", err)
+	}
+
+	stages, err := runner.Select(selection)
+	if err != nil {
+		return nil, err
+	}
+
+	return runner.Plan(ctx, stages)
+}
+
 // calculateStatistics calculates comprehensive statistics from the final anime list
 func calculateStatistics(animeList []domain.Anime, dupeCount int) domain.Statistics {
 	stats := domain.Statistics{
@@ -212,9 +312,7 @@ func calculateStatistics(animeList []domain.Anime, dupeCount int) domain.Statist
 }
 
 // GenerateMappings generates mapping files from master files
-func (a *App) GenerateMappings(rootPath string) error {
-	ctx := context.Background()
-
+func (a *App) GenerateMappings(ctx context.Context, rootPath string) error {
 	// Generate TMDB mapping
 	am, err := a.mappingRepo.GetTMDBMaster(ctx, filepath.Join(rootPath, "tmdb-mal-master.yaml"))
 	if err != nil {
@@ -255,12 +353,12 @@ func (a *App) GenerateMappings(rootPath string) error {
 }
 
 // FormatFiles formats the YAML mapping files
-func (a *App) FormatFiles(rootPath string) error {
-	if err := format.FormatTMDB(rootPath, a.mappingRepo); err != nil {
+func (a *App) FormatFiles(ctx context.Context, rootPath string) error {
+	if err := format.FormatTMDB(ctx, rootPath, a.mappingRepo); err != nil {
 		return fmt.Errorf("failed to format TMDB: %w", err)
 	}
 
-	if err := format.FormatTVDB(rootPath, a.mappingRepo); err != nil {
+	if err := format.FormatTVDB(ctx, rootPath, a.mappingRepo); err != nil {
 		return fmt.Errorf("failed to format TVDB: %w", err)
 	}
 
@@ -0,0 +1,367 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+
+	"github.com/varoOP/shinkrodb/internal/database"
+	"github.com/varoOP/shinkrodb/internal/domain"
+	"github.com/varoOP/shinkrodb/pkg/animelist"
+)
+
+// lookupData holds the sources a lookup reads, loaded once for all matched MAL IDs
+type lookupData struct {
+	files      map[domain.AnimeFile]map[int]domain.Anime
+	entries    map[int]*domain.MALCacheEntry
+	anidbIDs   map[int]int
+	tmdbIDs    map[int]int
+	attempts   map[int]*domain.ScrapeAttempt
+	animeList  *animelist.AnimeList
+	tvdbMaster map[int]domain.TVDBAnime
+	tmdbMaster map[int]domain.AnimeMovie
+}
+
+// lookupFiles are the output files searched by a lookup, most complete first
+var lookupFiles = []domain.AnimeFile{domain.ShinkroFile, domain.TMDBFile, domain.TVDBFile, domain.AniDBFile, domain.MalIDFile}
+
+// Lookup reports everything known about the entries matching id, read from the cache, the output files,
+// anime-list.xml and the master files. Every MAL ID mapped to id is reported.
+// With refresh, the AniDB, TVDB and TMDB IDs of each entry are re-resolved live and the cache is updated;
+// the output files change on the next run.
+func (a *App) Lookup(ctx context.Context, rootPath string, source domain.IDSource, id int, refresh bool) ([]*domain.LookupResult, error) {
+	// Without a cache database only the files are searched
+	var cacheRepo domain.CacheRepo
+	if refresh {
+		db, err := database.NewDB(".", a.log)
+		if err != nil {
+			return nil, fmt.Errorf("failed to initialize database: %w", err)
+		}
+		defer db.Close()
+
+		a.useAnimeStore(db)
+		cacheRepo = database.NewCacheRepo(a.log, db)
+	} else if _, err := os.Stat(filepath.Join(".", "shinkrodb.db")); err == nil {
+		db, err := database.NewReadOnlyDB(".", a.log)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open database: %w", err)
+		}
+		defer db.Close()
+
+		a.useAnimeStore(db)
+		cacheRepo = database.NewCacheRepo(a.log, db)
+	}
+
+	a.setRootPath(rootPath)
+
+	data, err := a.loadLookupData(ctx, rootPath, cacheRepo)
+	if err != nil {
+		return nil, err
+	}
+
+	results := []*domain.LookupResult{}
+	for _, malID := range data.resolve(source, id) {
+		result, err := a.lookupEntry(ctx, cacheRepo, data, malID)
+		if err != nil {
+			return nil, err
+		}
+
+		if refresh {
+			if err := a.refreshEntry(ctx, cacheRepo, data, result); err != nil {
+				return nil, err
+			}
+		}
+
+		results = append(results, result)
+	}
+
+	return results, nil
+}
+
+// loadLookupData loads the output files, the cache tables, anime-list.xml and the master files.
+// Missing sources are skipped, so a lookup works on a partial tree.
+func (a *App) loadLookupData(ctx context.Context, rootPath string, cacheRepo domain.CacheRepo) (*lookupData, error) {
+	data := &lookupData{
+		files:      make(map[domain.AnimeFile]map[int]domain.Anime),
+		entries:    make(map[int]*domain.MALCacheEntry),
+		tvdbMaster: make(map[int]domain.TVDBAnime),
+		tmdbMaster: make(map[int]domain.AnimeMovie),
+	}
+
+	for _, file := range lookupFiles {
+		byMalID := make(map[int]domain.Anime)
+		for v, err := range a.animeRepo.Iter(ctx, domain.AnimePath(filepath.Join(a.paths.RootDir, string(file)))) {
+			if err != nil {
+				a.log.Trace().Err(err).Str("file", string(file)).Msg("output file not available")
+				byMalID = nil
+				break
+			}
+			byMalID[v.MalID] = v
+		}
+		if byMalID != nil {
+			data.files[file] = byMalID
+		}
+	}
+
+	if cacheRepo != nil {
+		entries, err := cacheRepo.GetMALEntries(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get MAL cache entries: %w", err)
+		}
+		for _, entry := range entries {
+			data.entries[entry.MalID] = entry
+		}
+
+		if data.anidbIDs, err = cacheRepo.GetAniDBIDs(ctx); err != nil {
+			return nil, fmt.Errorf("failed to get cached AniDB IDs: %w", err)
+		}
+		if data.tmdbIDs, err = cacheRepo.GetTMDBIDs(ctx); err != nil {
+			return nil, fmt.Errorf("failed to get cached TMDB IDs: %w", err)
+		}
+		if data.attempts, err = cacheRepo.GetScrapeAttempts(ctx); err != nil {
+			return nil, fmt.Errorf("failed to get scrape attempts: %w", err)
+		}
+	}
+
+	// Load anime-list.xml from current directory (./), same as run
+	al, err := animelist.NewAnimeList(ctx, ".", animelist.WithOffline(a.config.Offline))
+	if err != nil {
+		a.log.Warn().Err(err).Msg("failed to load anime-list.xml, lookup will not include it")
+	} else {
+		data.animeList = al
+	}
+
+	if tvdb, err := a.mappingRepo.GetTVDBMaster(ctx, filepath.Join(rootPath, "tvdb-mal-master.yaml")); err == nil {
+		for _, v := range tvdb.Anime {
+			data.tvdbMaster[v.Malid] = v
+		}
+	}
+
+	if tmdb, err := a.mappingRepo.GetTMDBMaster(ctx, filepath.Join(rootPath, "tmdb-mal-master.yaml")); err == nil {
+		for _, v := range tmdb.AnimeMovie {
+			data.tmdbMaster[v.MALID] = v
+		}
+	}
+
+	return data, nil
+}
+
+// resolve returns the sorted MAL IDs mapped to id in any of the loaded sources
+func (d *lookupData) resolve(source domain.IDSource, id int) []int {
+	malIDs := make(map[int]bool)
+
+	// anidbToMal adds the MAL IDs of an AniDB ID, used for the anime-list.xml reverse lookups
+	anidbToMal := func(anidbID int) {
+		for malID, v := range d.anidbIDs {
+			if v == anidbID {
+				malIDs[malID] = true
+			}
+		}
+		for _, anime := range d.files {
+			for malID, v := range anime {
+				if v.AnidbID == anidbID {
+					malIDs[malID] = true
+				}
+			}
+		}
+	}
+
+	switch source {
+	case domain.IDSourceMAL:
+		if d.known(id) {
+			malIDs[id] = true
+		}
+
+	case domain.IDSourceAniDB:
+		anidbToMal(id)
+
+	case domain.IDSourceTVDB:
+		for _, anime := range d.files {
+			for malID, v := range anime {
+				if v.TvdbID == id {
+					malIDs[malID] = true
+				}
+			}
+		}
+		for malID, v := range d.tvdbMaster {
+			if v.Tvdbid == id {
+				malIDs[malID] = true
+			}
+		}
+		if d.animeList != nil {
+			for _, anidbID := range d.animeList.GetAnidbIDsByTvdbID(id) {
+				anidbToMal(anidbID)
+			}
+		}
+
+	case domain.IDSourceTMDB:
+		for malID, v := range d.tmdbIDs {
+			if v == id {
+				malIDs[malID] = true
+			}
+		}
+		for _, anime := range d.files {
+			for malID, v := range anime {
+				if v.TmdbID == id {
+					malIDs[malID] = true
+				}
+			}
+		}
+		for malID, v := range d.tmdbMaster {
+			if v.TMDBID == id {
+				malIDs[malID] = true
+			}
+		}
+		if d.animeList != nil {
+			for _, anidbID := range d.animeList.GetAnidbIDsByTmdbID(id) {
+				anidbToMal(anidbID)
+			}
+		}
+	}
+
+	sorted := make([]int, 0, len(malIDs))
+	for malID := range malIDs {
+		sorted = append(sorted, malID)
+	}
+	slices.Sort(sorted)
+	return sorted
+}
+
+// known reports whether a MAL ID appears in the cache, an output file or a master file
+func (d *lookupData) known(malID int) bool {
+	if _, ok := d.entries[malID]; ok {
+		return true
+	}
+	if _, ok := d.anidbIDs[malID]; ok {
+		return true
+	}
+	if _, ok := d.tmdbIDs[malID]; ok {
+		return true
+	}
+	for _, anime := range d.files {
+		if _, ok := anime[malID]; ok {
+			return true
+		}
+	}
+	if _, ok := d.tvdbMaster[malID]; ok {
+		return true
+	}
+	_, ok := d.tmdbMaster[malID]
+	return ok
+}
+
+// lookupEntry collects everything known about a single MAL ID
+func (a *App) lookupEntry(ctx context.Context, cacheRepo domain.CacheRepo, data *lookupData, malID int) (*domain.LookupResult, error) {
+	result := &domain.LookupResult{
+		MalID:         malID,
+		Cache:         data.entries[malID],
+		CachedAniDBID: data.anidbIDs[malID],
+		CachedTMDBID:  data.tmdbIDs[malID],
+		ScrapeAttempt: data.attempts[malID],
+	}
+
+	for _, file := range lookupFiles {
+		anime, ok := data.files[file][malID]
+		if !ok {
+			continue
+		}
+		result.Files = append(result.Files, file)
+		if result.Anime == nil {
+			result.Anime = &anime
+		}
+	}
+
+	// Dedupe drops entries from the shinkro output only, so they are still in the TMDB output
+	if _, ok := data.files[domain.ShinkroFile]; ok {
+		_, inTMDB := data.files[domain.TMDBFile][malID]
+		_, inShinkro := data.files[domain.ShinkroFile][malID]
+		result.Deduped = inTMDB && !inShinkro
+	}
+
+	if cacheRepo != nil {
+		history, err := cacheRepo.GetMappingHistory(ctx, malID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get mapping history: %w", err)
+		}
+		result.History = history
+	}
+
+	anidbID := result.CachedAniDBID
+	if anidbID == 0 && result.Anime != nil {
+		anidbID = result.Anime.AnidbID
+	}
+	result.AnimeList = data.animeListEntry(anidbID)
+
+	if v, ok := data.tvdbMaster[malID]; ok {
+		result.TVDBMaster = &v
+	}
+	if v, ok := data.tmdbMaster[malID]; ok {
+		result.TMDBMaster = &v
+	}
+
+	return result, nil
+}
+
+// animeListEntry returns the anime-list.xml entry of an AniDB ID, or nil if it has none
+func (d *lookupData) animeListEntry(anidbID int) *domain.AnimeListEntry {
+	if d.animeList == nil || anidbID == 0 {
+		return nil
+	}
+
+	name := d.animeList.GetName(anidbID)
+	if name == "" {
+		return nil
+	}
+
+	return &domain.AnimeListEntry{
+		AnidbID:           anidbID,
+		TvdbID:            d.animeList.GetTvdbID(anidbID),
+		TmdbID:            d.animeList.GetTmdbID(anidbID),
+		DefaultTvdbSeason: d.animeList.GetDefaultTvdbSeason(anidbID),
+		Name:              name,
+	}
+}
+
+// refreshEntry re-resolves the AniDB ID of an entry with the configured resolvers, its TVDB ID from
+// anime-list.xml and, for movies, its TMDB ID, and updates the cache
+func (a *App) refreshEntry(ctx context.Context, cacheRepo domain.CacheRepo, data *lookupData, result *domain.LookupResult) error {
+	anime := domain.Anime{MalID: result.MalID}
+	if result.Anime != nil {
+		anime = *result.Anime
+	} else if result.Cache != nil {
+		anime.Type = result.Cache.Type
+		anime.ReleaseDate = result.Cache.ReleaseDate
+	}
+
+	refreshed := &domain.RefreshResult{}
+	result.Refreshed = refreshed
+
+	anidbID, provenance, err := a.malService.RefreshAniDBID(ctx, cacheRepo, anime)
+	if err != nil {
+		return fmt.Errorf("failed to refresh AniDB ID of MAL ID %d: %w", anime.MalID, err)
+	}
+	refreshed.AnidbID = anidbID
+	refreshed.AnidbProvenance = provenance
+	if anidbID > 0 {
+		anime.AnidbID = anidbID
+	}
+
+	// TVDB IDs only come from anime-list.xml, which is re-downloaded once the cached copy is a day old
+	if anime.AnidbID > 0 && data.animeList != nil {
+		refreshed.TvdbID = data.animeList.GetTvdbID(anime.AnidbID)
+	}
+
+	// The TMDB search needs the title, which is only kept in the output files
+	if anime.Type == "movie" && anime.MainTitle != "" {
+		tmdbID, provenance, err := a.tmdbService.RefreshTmdbID(ctx, cacheRepo, anime)
+		if err != nil {
+			return fmt.Errorf("failed to refresh TMDB ID of MAL ID %d: %w", anime.MalID, err)
+		}
+		refreshed.TmdbID = tmdbID
+		refreshed.TmdbProvenance = provenance
+	}
+
+	return nil
+}
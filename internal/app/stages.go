@@ -0,0 +1,263 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"iter"
+
+	"github.com/varoOP/shinkrodb/internal/domain"
+	"github.com/varoOP/shinkrodb/internal/pipeline"
+)
+
+// stages returns the stages of the run pipeline.
+// The runner orders them by their declared inputs and outputs.
+func (a *App) stages(rootPath string, cacheRepo domain.CacheRepo) []pipeline.Stage {
+	return []pipeline.Stage{
+		&malStage{app: a, cacheRepo: cacheRepo},
+		&anidbStage{app: a, cacheRepo: cacheRepo},
+		&tvdbStage{app: a, rootPath: rootPath},
+		&tmdbStage{app: a, rootPath: rootPath, cacheRepo: cacheRepo},
+		&dedupeStage{app: a, rootPath: rootPath, cacheRepo: cacheRepo},
+	}
+}
+
+// malStage fetches MAL IDs, updates mal_cache and tombstones MAL IDs missing from the ranking
+type malStage struct {
+	app       *App
+	cacheRepo domain.CacheRepo
+}
+
+func (s *malStage) Name() domain.Stage          { return domain.StageMAL }
+func (s *malStage) Inputs() []domain.AnimePath  { return nil }
+func (s *malStage) Outputs() []domain.AnimePath { return []domain.AnimePath{s.app.paths.MalIDPath} }
+
+func (s *malStage) Run(ctx context.Context, result *pipeline.Result) error {
+	total, tombstones, err := s.app.malService.GetAnimeIDs(ctx, s.cacheRepo)
+	if err != nil {
+		return fmt.Errorf("failed to get MAL IDs: %w", err)
+	}
+
+	result.Set("mal_ids", total)
+	result.Set("tombstoned", len(tombstones.Tombstoned))
+	result.Set("pending", len(tombstones.Pending))
+	result.Set("restored", len(tombstones.Restored))
+	result.Set("purged", len(tombstones.Purged))
+	return nil
+}
+
+func (s *malStage) Plan(ctx context.Context) (*domain.FetchPlan, error) {
+	return s.app.malService.PlanAnimeIDs(ctx)
+}
+
+// anidbStage resolves AniDB IDs for MAL IDs
+type anidbStage struct {
+	app       *App
+	cacheRepo domain.CacheRepo
+}
+
+func (s *anidbStage) Name() domain.Stage          { return domain.StageAniDB }
+func (s *anidbStage) Inputs() []domain.AnimePath  { return []domain.AnimePath{s.app.paths.MalIDPath} }
+func (s *anidbStage) Outputs() []domain.AnimePath { return []domain.AnimePath{s.app.paths.AniDBPath} }
+
+func (s *anidbStage) Run(ctx context.Context, result *pipeline.Result) error {
+	total, withAniDB, err := s.app.malService.ScrapeAniDBIDs(ctx, s.cacheRepo)
+	if err != nil {
+		return fmt.Errorf("failed to scrape MAL: %w", err)
+	}
+
+	result.Set("total", total)
+	result.Set("with_anidb", withAniDB)
+	return nil
+}
+
+func (s *anidbStage) Plan(ctx context.Context) (*domain.FetchPlan, error) {
+	return s.app.malService.PlanAniDBIDs(ctx, s.cacheRepo)
+}
+
+// tvdbStage maps TVDB IDs from AniDB IDs and updates the TVDB master
+type tvdbStage struct {
+	app      *App
+	rootPath string
+}
+
+func (s *tvdbStage) Name() domain.Stage          { return domain.StageTVDB }
+func (s *tvdbStage) Inputs() []domain.AnimePath  { return []domain.AnimePath{s.app.paths.AniDBPath} }
+func (s *tvdbStage) Outputs() []domain.AnimePath { return []domain.AnimePath{s.app.paths.TVDBPath} }
+
+func (s *tvdbStage) Run(ctx context.Context, result *pipeline.Result) error {
+	return pipeline.RunEnricher(ctx, s.app.animeRepo, s, result)
+}
+
+func (s *tvdbStage) Enrich(ctx context.Context, anime iter.Seq2[domain.Anime, error], result *pipeline.Result) (func(*domain.Anime), error) {
+	ids, err := s.app.tvdbService.EnrichTvdbIDs(ctx, s.rootPath, anime)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get TVDB IDs: %w", err)
+	}
+
+	result.Set("with_tvdb", len(ids))
+	return func(anime *domain.Anime) {
+		if tvdbID, ok := ids[anime.MalID]; ok {
+			anime.TvdbID = tvdbID
+		}
+	}, nil
+}
+
+func (s *tvdbStage) Plan(ctx context.Context) (*domain.FetchPlan, error) {
+	// TVDB IDs come from anime-list.xml, the result depends on its current content
+	return &domain.FetchPlan{Requests: 1, Note: "anime-list.xml, unless cached"}, nil
+}
+
+// tmdbStage maps TMDB IDs for movies and updates the TMDB master
+type tmdbStage struct {
+	app       *App
+	rootPath  string
+	cacheRepo domain.CacheRepo
+}
+
+func (s *tmdbStage) Name() domain.Stage          { return domain.StageTMDB }
+func (s *tmdbStage) Inputs() []domain.AnimePath  { return []domain.AnimePath{s.app.paths.TVDBPath} }
+func (s *tmdbStage) Outputs() []domain.AnimePath { return []domain.AnimePath{s.app.paths.TMDBPath} }
+
+func (s *tmdbStage) Run(ctx context.Context, result *pipeline.Result) error {
+	return pipeline.RunEnricher(ctx, s.app.animeRepo, s, result)
+}
+
+func (s *tmdbStage) Enrich(ctx context.Context, anime iter.Seq2[domain.Anime, error], result *pipeline.Result) (func(*domain.Anime), error) {
+	ids, err := s.app.tmdbService.EnrichTmdbIDs(ctx, s.rootPath, anime, s.cacheRepo)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get TMDB IDs: %w", err)
+	}
+
+	result.Set("with_tmdb", len(ids))
+	return func(anime *domain.Anime) {
+		if tmdbID, ok := ids[anime.MalID]; ok {
+			anime.TmdbID = tmdbID
+		}
+	}, nil
+}
+
+func (s *tmdbStage) Plan(ctx context.Context) (*domain.FetchPlan, error) {
+	return s.app.tmdbService.PlanTmdbIDs(ctx, s.cacheRepo)
+}
+
+// dedupeStage removes duplicates, writes the shinkro output and its reverse lookup indexes,
+// publishes it as compressed variants and a delta against the previous release and describes them in the manifest.
+// These extra files are not anime lists, so they are not declared as stage outputs.
+type dedupeStage struct {
+	app       *App
+	rootPath  string
+	cacheRepo domain.CacheRepo
+}
+
+func (s *dedupeStage) Name() domain.Stage         { return domain.StageDedupe }
+func (s *dedupeStage) Inputs() []domain.AnimePath { return []domain.AnimePath{s.app.paths.TMDBPath} }
+func (s *dedupeStage) Outputs() []domain.AnimePath {
+	return []domain.AnimePath{s.app.paths.ShinkroPath}
+}
+
+func (s *dedupeStage) Run(ctx context.Context, result *pipeline.Result) error {
+	input := s.app.animeRepo.Iter(ctx, s.app.paths.TMDBPath)
+
+	// Only the entries sharing a key with another one are checked, the others are streamed to the output
+	candidates, err := s.app.dedupeService.Candidates(ctx, input)
+	if err != nil {
+		return fmt.Errorf("failed to get dedupe candidates: %w", err)
+	}
+
+	// Episode counts, Japanese titles and synonyms are not part of the anime lists,
+	// the dedupe rules read them from mal_cache
+	if s.cacheRepo != nil && len(candidates) > 0 {
+		entries, err := s.cacheRepo.GetMALEntries(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to get MAL cache entries: %w", err)
+		}
+
+		byMalID := make(map[int]*domain.MALCacheEntry, len(entries))
+		for _, entry := range entries {
+			byMalID[entry.MalID] = entry
+		}
+		for i := range candidates {
+			if entry, ok := byMalID[candidates[i].MalID]; ok {
+				candidates[i].Episodes = entry.Episodes
+				candidates[i].JapaneseTitle = entry.JapaneseTitle
+				candidates[i].Synonyms = entry.Synonyms
+			}
+		}
+	}
+
+	report, deduped, err := s.app.dedupeService.CheckDupes(ctx, candidates)
+	if err != nil {
+		return fmt.Errorf("failed to check dupes: %w", err)
+	}
+
+	// The TVDB seasons are loaded once for the collisions and the TVDB index
+	seasons := s.app.indexService.Seasons(ctx, s.rootPath)
+
+	// Colliding TMDB and TVDB IDs break shinkro's reverse lookups
+	collisions, deduped, err := s.app.dedupeService.CheckCollisions(ctx, deduped, seasons.Season)
+	if err != nil {
+		return fmt.Errorf("failed to check collisions: %w", err)
+	}
+
+	removed := make(map[int]bool, len(candidates)-len(deduped))
+	for _, a := range candidates {
+		removed[a.MalID] = true
+	}
+	for _, a := range deduped {
+		delete(removed, a.MalID)
+	}
+
+	// The delta is computed against the release this run replaces
+	previous, err := s.app.publishService.Previous(ctx)
+	if err != nil {
+		return err
+	}
+
+	total := 0
+	kept := func(yield func(domain.Anime, error) bool) {
+		for anime, err := range input {
+			if err == nil {
+				if removed[anime.MalID] {
+					continue
+				}
+				total++
+			}
+			if !yield(anime, err) || err != nil {
+				return
+			}
+		}
+	}
+
+	if err := s.app.animeRepo.StoreIter(ctx, s.app.paths.ShinkroPath, kept); err != nil {
+		return fmt.Errorf("failed to store deduped anime: %w", err)
+	}
+
+	if err := s.app.publishService.Publish(ctx, previous); err != nil {
+		return fmt.Errorf("failed to publish shinkro output: %w", err)
+	}
+
+	indexes, err := s.app.indexService.GenerateIndexes(ctx, s.app.animeRepo.Iter(ctx, s.app.paths.ShinkroPath), seasons)
+	if err != nil {
+		return fmt.Errorf("failed to generate indexes: %w", err)
+	}
+
+	// The AniDB groups and the collisions are reported together, for curators to review and override
+	all := &domain.DedupeReport{Groups: append(report.Groups, collisions.Groups...)}
+	if err := s.app.dedupeService.StoreReport(ctx, all); err != nil {
+		return err
+	}
+
+	// The manifest records the anime-list.xml the seasons were loaded from
+	if _, err := s.app.manifestService.Generate(ctx, seasons.AnimeListSHA256()); err != nil {
+		return fmt.Errorf("failed to generate manifest: %w", err)
+	}
+
+	result.Set("total", total)
+	result.Set("dupes", len(report.Groups))
+	result.Set("collisions", len(collisions.Groups))
+	result.Set("removed", report.Removed()+collisions.Removed())
+	result.Set("anidb_index", len(indexes.AniDB))
+	result.Set("tvdb_index", len(indexes.TVDB))
+	result.Set("tmdb_index", len(indexes.TMDB))
+	return nil
+}
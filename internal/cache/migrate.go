@@ -147,7 +147,7 @@ func MigrateCache(ctx context.Context, cacheDir, rootPath, dbPath string, animeR
 			// Insert into database using new separate tables
 			// Note: mal_cache should only be updated in GetAnimeIDs, not here
 			// Update AniDB cache only
-			if err := cacheRepo.UpsertAniDB(ctx, malID, anidbID); err != nil {
+			if err := cacheRepo.UpsertAniDB(ctx, malID, anidbID, domain.ProvenanceCacheMigration); err != nil {
 				log.Warn().Err(err).Int("mal_id", malID).Str("path", path).Msg("failed to insert AniDB cache")
 				errorCount++
 				return nil
@@ -194,7 +194,7 @@ func MigrateCache(ctx context.Context, cacheDir, rootPath, dbPath string, animeR
 			updatedCount := 0
 			for malID, anime := range animeMap {
 				// UpsertTMDB will update existing entries or create new ones if they don't exist
-				if err := cacheRepo.UpsertTMDB(ctx, malID, anime.TmdbID); err != nil {
+				if err := cacheRepo.UpsertTMDB(ctx, malID, anime.TmdbID, domain.ProvenanceCacheMigration); err != nil {
 					log.Warn().Err(err).Int("mal_id", malID).Int("tmdb_id", anime.TmdbID).Msg("failed to update/create TMDB ID")
 					continue
 				}
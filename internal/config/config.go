@@ -2,6 +2,9 @@ package config
 
 import (
 	"fmt"
+	"slices"
+	"strings"
+	"time"
 
 	"github.com/spf13/viper"
 	"github.com/varoOP/shinkrodb/internal/domain"
@@ -48,6 +51,204 @@ func Load() (*domain.Config, error) {
 		}
 	}
 
+	// Tombstone grace period (default: 30 days)
+	cfg.TombstoneGraceDays = 30
+	if viper.IsSet("tombstone_grace_days") {
+		cfg.TombstoneGraceDays = viper.GetInt("tombstone_grace_days")
+		if cfg.TombstoneGraceDays < 0 {
+			return nil, fmt.Errorf("invalid tombstone_grace_days: %d (must be 0 or greater)", cfg.TombstoneGraceDays)
+		}
+	}
+
+	// AniDB scrape budget per run (default: 500, 0 disables the limit)
+	cfg.MaxScrapes = 500
+	if viper.IsSet("max_scrapes") {
+		cfg.MaxScrapes = viper.GetInt("max_scrapes")
+		if cfg.MaxScrapes < 0 {
+			return nil, fmt.Errorf("invalid max_scrapes: %d (must be 0 or greater)", cfg.MaxScrapes)
+		}
+	}
+
+	// AniDB resolvers (default: scraper only)
+	cfg.AniDBResolvers = viper.GetStringSlice("anidb_resolvers")
+	if len(cfg.AniDBResolvers) == 0 {
+		cfg.AniDBResolvers = []string{domain.AniDBResolverScraper}
+	}
+	cfg.AniDBMappingFile = viper.GetString("anidb_mapping_file")
+	for _, name := range cfg.AniDBResolvers {
+		switch name {
+		case domain.AniDBResolverScraper, domain.AniDBResolverAnimeList:
+		case domain.AniDBResolverMappingFile:
+			if cfg.AniDBMappingFile == "" {
+				return nil, fmt.Errorf("anidb_mapping_file is required when the '%s' resolver is enabled", domain.AniDBResolverMappingFile)
+			}
+		default:
+			return nil, fmt.Errorf("invalid anidb_resolvers entry: %s (must be '%s', '%s', or '%s')", name, domain.AniDBResolverScraper, domain.AniDBResolverMappingFile, domain.AniDBResolverAnimeList)
+		}
+	}
+
+	// Scraper breakage detection (default: warn below a 50% hit ratio)
+	cfg.ScrapeMinHitRatio = 0.5
+	if viper.IsSet("scrape_min_hit_ratio") {
+		cfg.ScrapeMinHitRatio = viper.GetFloat64("scrape_min_hit_ratio")
+		if cfg.ScrapeMinHitRatio < 0 || cfg.ScrapeMinHitRatio > 1 {
+			return nil, fmt.Errorf("invalid scrape_min_hit_ratio: %v (must be between 0 and 1)", cfg.ScrapeMinHitRatio)
+		}
+	}
+
+	breakageActionStr := viper.GetString("scrape_breakage_action")
+	if breakageActionStr == "" {
+		cfg.ScrapeBreakageAction = domain.ScrapeBreakageWarn
+	} else {
+		cfg.ScrapeBreakageAction = domain.ScrapeBreakageAction(breakageActionStr)
+		if cfg.ScrapeBreakageAction != domain.ScrapeBreakageWarn &&
+			cfg.ScrapeBreakageAction != domain.ScrapeBreakageFail {
+			return nil, fmt.Errorf("invalid scrape_breakage_action: %s (must be 'warn' or 'fail')", breakageActionStr)
+		}
+	}
+
+	// MAL scraper tuning
+	cfg.ScrapeParallelism = 2
+	if viper.IsSet("scrape_parallelism") {
+		cfg.ScrapeParallelism = viper.GetInt("scrape_parallelism")
+		if cfg.ScrapeParallelism < 1 {
+			return nil, fmt.Errorf("invalid scrape_parallelism: %d (must be 1 or greater)", cfg.ScrapeParallelism)
+		}
+	}
+
+	cfg.ScrapeDelay = time.Second
+	if viper.IsSet("scrape_delay") {
+		cfg.ScrapeDelay = viper.GetDuration("scrape_delay")
+	}
+
+	cfg.ScrapeRandomDelay = time.Second
+	if viper.IsSet("scrape_random_delay") {
+		cfg.ScrapeRandomDelay = viper.GetDuration("scrape_random_delay")
+	}
+
+	cfg.ScrapeTimeout = 30 * time.Second
+	if viper.IsSet("scrape_timeout") {
+		cfg.ScrapeTimeout = viper.GetDuration("scrape_timeout")
+		if cfg.ScrapeTimeout <= 0 {
+			return nil, fmt.Errorf("invalid scrape_timeout: %s (must be greater than 0)", viper.GetString("scrape_timeout"))
+		}
+	}
+
+	cfg.ScrapeRetries = 2
+	if viper.IsSet("scrape_retries") {
+		cfg.ScrapeRetries = viper.GetInt("scrape_retries")
+		if cfg.ScrapeRetries < 0 {
+			return nil, fmt.Errorf("invalid scrape_retries: %d (must be 0 or greater)", cfg.ScrapeRetries)
+		}
+	}
+
+	// TMDB lookup concurrency (default: 4)
+	cfg.TMDBConcurrency = 4
+	if viper.IsSet("tmdb_concurrency") {
+		cfg.TMDBConcurrency = viper.GetInt("tmdb_concurrency")
+		if cfg.TMDBConcurrency < 1 {
+			return nil, fmt.Errorf("invalid tmdb_concurrency: %d (must be 1 or greater)", cfg.TMDBConcurrency)
+		}
+	}
+
+	// TMDB rate limit in requests per second (default: 40, TMDB allows around 50)
+	cfg.TMDBRateLimit = 40
+	if viper.IsSet("tmdb_rate_limit") {
+		cfg.TMDBRateLimit = viper.GetFloat64("tmdb_rate_limit")
+		if cfg.TMDBRateLimit <= 0 {
+			return nil, fmt.Errorf("invalid tmdb_rate_limit: %v (must be greater than 0)", cfg.TMDBRateLimit)
+		}
+	}
+
+	cfg.TMDBTimeout = 15 * time.Second
+	if viper.IsSet("tmdb_timeout") {
+		cfg.TMDBTimeout = viper.GetDuration("tmdb_timeout")
+		if cfg.TMDBTimeout <= 0 {
+			return nil, fmt.Errorf("invalid tmdb_timeout: %s (must be greater than 0)", viper.GetString("tmdb_timeout"))
+		}
+	}
+
+	// Additional output formats per anime list output (default: JSON only)
+	cfg.OutputFormats = make(map[domain.AnimeFile][]domain.OutputFormat)
+	for name, formats := range viper.GetStringMapStringSlice("output_formats") {
+		file := domain.AnimeFile(name + ".json")
+		if !slices.Contains(domain.AnimeFiles, file) {
+			return nil, fmt.Errorf("invalid output_formats key: %s (must be an output file name without .json, e.g. 'for-shinkro')", name)
+		}
+		for _, format := range formats {
+			if !slices.Contains(domain.OutputFormats, domain.OutputFormat(format)) {
+				return nil, fmt.Errorf("invalid output_formats entry for %s: %s (must be 'ndjson', 'csv', or 'sqlite')", name, format)
+			}
+			cfg.OutputFormats[file] = append(cfg.OutputFormats[file], domain.OutputFormat(format))
+		}
+	}
+
+	// Anime list storage (default: one JSON file per stage output)
+	animeStoreStr := viper.GetString("anime_store")
+	if animeStoreStr == "" {
+		cfg.AnimeStore = domain.AnimeStoreFile
+	} else {
+		cfg.AnimeStore = domain.AnimeStore(animeStoreStr)
+		if cfg.AnimeStore != domain.AnimeStoreFile &&
+			cfg.AnimeStore != domain.AnimeStoreSQLite {
+			return nil, fmt.Errorf("invalid anime_store: %s (must be 'file' or 'sqlite')", animeStoreStr)
+		}
+	}
+
+	for _, name := range viper.GetStringSlice("json_exports") {
+		file := domain.AnimeFile(name + ".json")
+		if !slices.Contains(domain.AnimeFiles, file) {
+			return nil, fmt.Errorf("invalid json_exports entry: %s (must be an output file name without .json, e.g. 'malid-anidbid')", name)
+		}
+		cfg.JSONExports = append(cfg.JSONExports, file)
+	}
+
+	// Other formats are exported from the JSON files, so the sqlite store has to write them
+	if cfg.AnimeStore == domain.AnimeStoreSQLite {
+		for file := range cfg.OutputFormats {
+			if file != domain.ShinkroFile && !slices.Contains(cfg.JSONExports, file) {
+				return nil, fmt.Errorf("invalid output_formats key: %s (must be listed in json_exports with anime_store 'sqlite')", strings.TrimSuffix(string(file), ".json"))
+			}
+		}
+	}
+
+	// Dedupe rules (default: title comparison only)
+	cfg.DedupeRules = viper.GetStringSlice("dedupe_rules")
+	if len(cfg.DedupeRules) == 0 {
+		cfg.DedupeRules = []string{domain.DedupeRuleTitle}
+	}
+	cfg.DedupeOverrideFile = viper.GetString("dedupe_override_file")
+	cfg.AniDBMetadataFile = viper.GetString("anidb_metadata_file")
+	for _, name := range cfg.DedupeRules {
+		switch name {
+		case domain.DedupeRuleTitle:
+		case domain.DedupeRuleOverride:
+			if cfg.DedupeOverrideFile == "" {
+				return nil, fmt.Errorf("dedupe_override_file is required when the '%s' dedupe rule is enabled", domain.DedupeRuleOverride)
+			}
+		case domain.DedupeRuleStartDate, domain.DedupeRuleEpisodes:
+			if cfg.AniDBMetadataFile == "" {
+				return nil, fmt.Errorf("anidb_metadata_file is required when the '%s' dedupe rule is enabled", name)
+			}
+		default:
+			return nil, fmt.Errorf("invalid dedupe_rules entry: %s (must be '%s', '%s', '%s', or '%s')", name, domain.DedupeRuleOverride, domain.DedupeRuleTitle, domain.DedupeRuleStartDate, domain.DedupeRuleEpisodes)
+		}
+	}
+
+	// TMDB and TVDB collisions (default: report only)
+	cfg.CollisionPolicy = domain.CollisionPolicy(viper.GetString("collision_policy"))
+	if cfg.CollisionPolicy == "" {
+		cfg.CollisionPolicy = domain.CollisionKeepAll
+	}
+	if cfg.CollisionPolicy != domain.CollisionKeepAll &&
+		cfg.CollisionPolicy != domain.CollisionKeepBest &&
+		cfg.CollisionPolicy != domain.CollisionDropAll {
+		return nil, fmt.Errorf("invalid collision_policy: %s (must be 'keep-all', 'keep-best', or 'drop-all')", cfg.CollisionPolicy)
+	}
+
+	// Only use cached remote files (set by the --offline run flag)
+	cfg.Offline = viper.GetBool("offline")
+
 	// Validate required fields
 	if cfg.MalClientID == "" {
 		return nil, fmt.Errorf("mal_client_id is required (set via config.toml or SHINKRODB_MAL_CLIENT_ID environment variable)")
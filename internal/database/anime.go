@@ -0,0 +1,260 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"iter"
+	"path/filepath"
+	"slices"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog"
+	"github.com/varoOP/shinkrodb/internal/domain"
+)
+
+// AnimeRepo implements domain.AnimeRepository with the anime lists stored in shinkrodb.db, keyed by the stage writing them.
+// Stored lists are also written as JSON for the files in exports, and stages that were never stored
+// are read from their JSON file, so switching from the file store keeps the files of previous runs usable.
+type AnimeRepo struct {
+	log     zerolog.Logger
+	db      *DB
+	files   domain.AnimeRepository
+	exports []domain.AnimeFile
+}
+
+var _ domain.AnimeRepository = (*AnimeRepo)(nil)
+
+// NewAnimeRepo creates a new anime repository.
+// files writes the JSON exports; the shinkro output is always exported since it is the published file.
+func NewAnimeRepo(log zerolog.Logger, db *DB, files domain.AnimeRepository, exports []domain.AnimeFile) *AnimeRepo {
+	return &AnimeRepo{
+		log:     log.With().Str("repo", "anime").Logger(),
+		db:      db,
+		files:   files,
+		exports: append(slices.Clone(exports), domain.ShinkroFile),
+	}
+}
+
+// stage returns the stage key of an anime list output path
+func (r *AnimeRepo) stage(path domain.AnimePath) (domain.Stage, error) {
+	stage, ok := domain.AnimeFile(filepath.Base(string(path))).Stage()
+	if !ok {
+		return "", errors.Errorf("not an anime list output: %s", path)
+	}
+
+	return stage, nil
+}
+
+// Get retrieves the anime list stored for the stage of path
+func (r *AnimeRepo) Get(ctx context.Context, path domain.AnimePath) ([]domain.Anime, error) {
+	a := []domain.Anime{}
+	for anime, err := range r.Iter(ctx, path) {
+		if err != nil {
+			return nil, err
+		}
+		a = append(a, anime)
+	}
+
+	return a, nil
+}
+
+// Iter streams the anime list stored for the stage of path in the order it was stored.
+// Stages that were never stored are read from their JSON file.
+func (r *AnimeRepo) Iter(ctx context.Context, path domain.AnimePath) iter.Seq2[domain.Anime, error] {
+	return func(yield func(domain.Anime, error) bool) {
+		stage, err := r.stage(path)
+		if err != nil {
+			yield(domain.Anime{}, err)
+			return
+		}
+
+		exists, err := r.exists(ctx, stage)
+		if err != nil {
+			yield(domain.Anime{}, err)
+			return
+		}
+		if !exists {
+			// Fall back to the JSON file of a run before switching to this store
+			for a, err := range r.files.Iter(ctx, path) {
+				if !yield(a, err) || err != nil {
+					return
+				}
+			}
+			return
+		}
+
+		queryBuilder := r.db.squirrel.
+			Select("mal_id", "title", "en_title", "anidb_id", "tvdb_id", "tmdb_id", "type", "release_date").
+			From("anime_stage").
+			Where(sq.Eq{"stage": stage}).
+			OrderBy("position")
+
+		query, args, err := queryBuilder.ToSql()
+		if err != nil {
+			yield(domain.Anime{}, errors.Wrap(err, "error building query"))
+			return
+		}
+
+		r.log.Trace().Str("query", query).Interface("args", args).Msg("Iter")
+
+		rows, err := r.db.handler.QueryContext(ctx, query, args...)
+		if err != nil {
+			yield(domain.Anime{}, errors.Wrap(err, "error executing query"))
+			return
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			var enTitle, releaseDate sql.NullString
+			var anidbID, tvdbID, tmdbID sql.NullInt64
+			a := domain.Anime{}
+			if err := rows.Scan(&a.MalID, &a.MainTitle, &enTitle, &anidbID, &tvdbID, &tmdbID, &a.Type, &releaseDate); err != nil {
+				yield(domain.Anime{}, errors.Wrap(err, "error scanning row"))
+				return
+			}
+			a.EnglishTitle = enTitle.String
+			a.AnidbID = int(anidbID.Int64)
+			a.TvdbID = int(tvdbID.Int64)
+			a.TmdbID = int(tmdbID.Int64)
+			a.ReleaseDate = releaseDate.String
+
+			if !yield(a, nil) {
+				return
+			}
+		}
+
+		if err := rows.Err(); err != nil {
+			yield(domain.Anime{}, errors.Wrap(err, "error iterating rows"))
+		}
+	}
+}
+
+// Store replaces the anime list stored for the stage of path
+func (r *AnimeRepo) Store(ctx context.Context, path domain.AnimePath, anime []domain.Anime) error {
+	return r.StoreIter(ctx, path, func(yield func(domain.Anime, error) bool) {
+		for _, a := range anime {
+			if !yield(a, nil) {
+				return
+			}
+		}
+	})
+}
+
+// StoreIter replaces the anime list stored for the stage of path in a single transaction,
+// so a failed or cancelled run keeps the previous list. seq may read the list being replaced.
+func (r *AnimeRepo) StoreIter(ctx context.Context, path domain.AnimePath, seq iter.Seq2[domain.Anime, error]) error {
+	stage, err := r.stage(path)
+	if err != nil {
+		return err
+	}
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	deleteQuery, deleteArgs, err := r.db.squirrel.
+		Delete("anime_stage").
+		Where(sq.Eq{"stage": stage}).
+		ToSql()
+	if err != nil {
+		return errors.Wrap(err, "error building query")
+	}
+
+	r.log.Trace().Str("query", deleteQuery).Interface("args", deleteArgs).Msg("StoreIter")
+
+	if _, err := tx.ExecContext(ctx, deleteQuery, deleteArgs...); err != nil {
+		return errors.Wrap(err, "error executing query")
+	}
+
+	stmt, err := tx.PrepareContext(ctx, "INSERT INTO anime_stage (stage, position, mal_id, title, en_title, anidb_id, tvdb_id, tmdb_id, type, release_date) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)")
+	if err != nil {
+		return errors.Wrap(err, "error preparing query")
+	}
+	defer stmt.Close()
+
+	// seq reading this stage sees the committed list, the WAL keeps readers apart from this transaction
+	count := 0
+	for a, err := range seq {
+		if err != nil {
+			return err
+		}
+		if _, err := stmt.ExecContext(ctx, stage, count, a.MalID, a.MainTitle, nullString(a.EnglishTitle), nullInt(a.AnidbID), nullInt(a.TvdbID), nullInt(a.TmdbID), a.Type, a.ReleaseDate); err != nil {
+			return errors.Wrapf(err, "error inserting anime %d", a.MalID)
+		}
+		count++
+	}
+
+	runQuery, runArgs, err := r.db.squirrel.
+		Replace("anime_stage_runs").
+		Columns("stage", "count", "stored_at").
+		Values(stage, count, time.Now().Format(time.RFC3339)).
+		ToSql()
+	if err != nil {
+		return errors.Wrap(err, "error building query")
+	}
+
+	if _, err := tx.ExecContext(ctx, runQuery, runArgs...); err != nil {
+		return errors.Wrap(err, "error executing query")
+	}
+
+	if err := tx.Commit(); err != nil {
+		return errors.Wrap(err, "error committing transaction")
+	}
+
+	r.log.Debug().Str("stage", string(stage)).Int("count", count).Msg("stored anime data")
+
+	if slices.Contains(r.exports, domain.AnimeFile(filepath.Base(string(path)))) {
+		if err := r.files.StoreIter(ctx, path, r.Iter(ctx, path)); err != nil {
+			return errors.Wrap(err, "failed to export json")
+		}
+	}
+
+	return nil
+}
+
+// Exists reports whether an anime list was stored for the stage of path, or its JSON file exists
+func (r *AnimeRepo) Exists(ctx context.Context, path domain.AnimePath) (bool, error) {
+	stage, err := r.stage(path)
+	if err != nil {
+		return false, err
+	}
+
+	exists, err := r.exists(ctx, stage)
+	if err != nil || exists {
+		return exists, err
+	}
+
+	return r.files.Exists(ctx, path)
+}
+
+func (r *AnimeRepo) exists(ctx context.Context, stage domain.Stage) (bool, error) {
+	query, args, err := r.db.squirrel.
+		Select("COUNT(*)").
+		From("anime_stage_runs").
+		Where(sq.Eq{"stage": stage}).
+		ToSql()
+	if err != nil {
+		return false, errors.Wrap(err, "error building query")
+	}
+
+	var count int
+	if err := r.db.handler.QueryRowContext(ctx, query, args...).Scan(&count); err != nil {
+		return false, errors.Wrap(err, "error executing query")
+	}
+
+	return count > 0, nil
+}
+
+// nullInt converts an external ID, 0 (unset) becomes NULL
+func nullInt(id int) sql.NullInt64 {
+	return sql.NullInt64{Int64: int64(id), Valid: id != 0}
+}
+
+// nullString converts an optional string, "" becomes NULL
+func nullString(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: s != ""}
+}
@@ -2,6 +2,8 @@ package database
 
 import (
 	"context"
+	"database/sql"
+	"encoding/json"
 	"time"
 
 	sq "github.com/Masterminds/squirrel"
@@ -24,14 +26,23 @@ func NewCacheRepo(log zerolog.Logger, db *DB) domain.CacheRepo {
 	}
 }
 
-// UpsertMAL inserts or updates a MAL cache entry
-func (r *CacheRepo) UpsertMAL(ctx context.Context, malID int, url, releaseDate, animeType string) error {
+// UpsertMAL inserts or updates a MAL cache entry. Synonyms are stored as a JSON array.
+func (r *CacheRepo) UpsertMAL(ctx context.Context, malID int, url, releaseDate, animeType, status string, episodes int, japaneseTitle string, synonyms []string) error {
 	now := time.Now().Format(time.RFC3339)
 
+	var synonymsJSON sql.NullString
+	if len(synonyms) > 0 {
+		j, err := json.Marshal(synonyms)
+		if err != nil {
+			return errors.Wrap(err, "error marshaling synonyms")
+		}
+		synonymsJSON = sql.NullString{String: string(j), Valid: true}
+	}
+
 	queryBuilder := r.db.squirrel.
 		Replace("mal_cache").
-		Columns("mal_id", "url", "release_date", "type", "cached_at", "last_used").
-		Values(malID, url, releaseDate, animeType, now, now)
+		Columns("mal_id", "url", "release_date", "type", "status", "episodes", "japanese_title", "synonyms", "cached_at", "last_used").
+		Values(malID, url, releaseDate, animeType, status, episodes, japaneseTitle, synonymsJSON, now, now)
 
 	query, args, err := queryBuilder.ToSql()
 	if err != nil {
@@ -48,6 +59,79 @@ func (r *CacheRepo) UpsertMAL(ctx context.Context, malID int, url, releaseDate,
 	return nil
 }
 
+// GetMALEntries returns all MAL cache entries, including tombstoned ones
+func (r *CacheRepo) GetMALEntries(ctx context.Context) ([]*domain.MALCacheEntry, error) {
+	queryBuilder := r.db.squirrel.
+		Select("mal_id", "url", "release_date", "type", "status", "episodes", "japanese_title", "synonyms", "cached_at", "last_used", "tombstoned_at").
+		From("mal_cache")
+
+	query, args, err := queryBuilder.ToSql()
+	if err != nil {
+		return nil, errors.Wrap(err, "error building query")
+	}
+
+	r.log.Trace().Str("query", query).Interface("args", args).Msg("GetMALEntries")
+
+	rows, err := r.db.handler.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, errors.Wrap(err, "error executing query")
+	}
+	defer rows.Close()
+
+	var entries []*domain.MALCacheEntry
+	for rows.Next() {
+		var releaseDate, animeType, status, japaneseTitle, synonyms, tombstonedAt sql.NullString
+		var episodes sql.NullInt64
+		entry := &domain.MALCacheEntry{}
+		if err := rows.Scan(&entry.MalID, &entry.URL, &releaseDate, &animeType, &status, &episodes, &japaneseTitle, &synonyms, &entry.CachedAt, &entry.LastUsed, &tombstonedAt); err != nil {
+			return nil, errors.Wrap(err, "error scanning row")
+		}
+		if synonyms.Valid {
+			if err := json.Unmarshal([]byte(synonyms.String), &entry.Synonyms); err != nil {
+				return nil, errors.Wrapf(err, "error unmarshaling synonyms of MAL ID %d", entry.MalID)
+			}
+		}
+		entry.JapaneseTitle = japaneseTitle.String
+		entry.ReleaseDate = releaseDate.String
+		entry.Type = animeType.String
+		entry.Status = status.String
+		entry.Episodes = int(episodes.Int64)
+		entry.TombstonedAt = tombstonedAt.String
+		entries = append(entries, entry)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, errors.Wrap(err, "error iterating rows")
+	}
+
+	return entries, nil
+}
+
+// TombstoneMAL marks a MAL cache entry as no longer present in the ranking.
+// The original tombstone timestamp is kept if the entry is already tombstoned.
+func (r *CacheRepo) TombstoneMAL(ctx context.Context, malID int) error {
+	now := time.Now().Format(time.RFC3339)
+
+	queryBuilder := r.db.squirrel.
+		Update("mal_cache").
+		Set("tombstoned_at", now).
+		Where(sq.Eq{"mal_id": malID, "tombstoned_at": nil})
+
+	query, args, err := queryBuilder.ToSql()
+	if err != nil {
+		return errors.Wrap(err, "error building query")
+	}
+
+	r.log.Trace().Str("query", query).Interface("args", args).Msg("TombstoneMAL")
+
+	_, err = r.db.handler.ExecContext(ctx, query, args...)
+	if err != nil {
+		return errors.Wrap(err, "error executing query")
+	}
+
+	return nil
+}
+
 // GetAniDBIDs returns a map of MAL ID to AniDB ID for entries that have AniDB IDs
 func (r *CacheRepo) GetAniDBIDs(ctx context.Context) (map[int]int, error) {
 	queryBuilder := r.db.squirrel.
@@ -83,11 +167,23 @@ func (r *CacheRepo) GetAniDBIDs(ctx context.Context) (map[int]int, error) {
 	return result, nil
 }
 
-// UpsertAniDB inserts or updates an AniDB cache entry
-func (r *CacheRepo) UpsertAniDB(ctx context.Context, malID, anidbID int) error {
+// UpsertAniDB inserts or updates an AniDB cache entry.
+// A mapping_history row is appended when the AniDB ID changes.
+func (r *CacheRepo) UpsertAniDB(ctx context.Context, malID, anidbID int, provenance domain.Provenance) error {
 	now := time.Now().Format(time.RFC3339)
 	hadAniDBID := anidbID > 0
 
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	oldID, err := r.getMappedID(ctx, tx, "anidb_cache", "anidb_id", malID)
+	if err != nil {
+		return err
+	}
+
 	queryBuilder := r.db.squirrel.
 		Replace("anidb_cache").
 		Columns("mal_id", "anidb_id", "had_anidb_id", "cached_at", "last_used").
@@ -100,6 +196,87 @@ func (r *CacheRepo) UpsertAniDB(ctx context.Context, malID, anidbID int) error {
 
 	r.log.Trace().Str("query", query).Interface("args", args).Msg("UpsertAniDB")
 
+	_, err = tx.ExecContext(ctx, query, args...)
+	if err != nil {
+		return errors.Wrap(err, "error executing query")
+	}
+
+	if oldID != anidbID {
+		if err := r.insertHistory(ctx, tx, malID, domain.MappingSourceAniDB, oldID, anidbID, now, provenance); err != nil {
+			return err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return errors.Wrap(err, "error committing transaction")
+	}
+
+	return nil
+}
+
+// GetScrapeAttempts returns a map of MAL ID to its AniDB scrape attempt record
+func (r *CacheRepo) GetScrapeAttempts(ctx context.Context) (map[int]*domain.ScrapeAttempt, error) {
+	queryBuilder := r.db.squirrel.
+		Select("mal_id", "misses", "last_attempt_at").
+		From("scrape_attempts")
+
+	query, args, err := queryBuilder.ToSql()
+	if err != nil {
+		return nil, errors.Wrap(err, "error building query")
+	}
+
+	r.log.Trace().Str("query", query).Interface("args", args).Msg("GetScrapeAttempts")
+
+	rows, err := r.db.handler.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, errors.Wrap(err, "error executing query")
+	}
+	defer rows.Close()
+
+	result := make(map[int]*domain.ScrapeAttempt)
+	for rows.Next() {
+		attempt := &domain.ScrapeAttempt{}
+		if err := rows.Scan(&attempt.MalID, &attempt.Misses, &attempt.LastAttemptAt); err != nil {
+			return nil, errors.Wrap(err, "error scanning row")
+		}
+		result[attempt.MalID] = attempt
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, errors.Wrap(err, "error iterating rows")
+	}
+
+	return result, nil
+}
+
+// RecordScrapeAttempt records an AniDB scrape attempt for a MAL ID.
+// Misses are reset when an AniDB ID was found and incremented otherwise.
+func (r *CacheRepo) RecordScrapeAttempt(ctx context.Context, malID int, found bool) error {
+	now := time.Now().Format(time.RFC3339)
+
+	misses := 1
+	if found {
+		misses = 0
+	}
+
+	queryBuilder := r.db.squirrel.
+		Insert("scrape_attempts").
+		Columns("mal_id", "misses", "last_attempt_at").
+		Values(malID, misses, now)
+
+	if found {
+		queryBuilder = queryBuilder.Suffix("ON CONFLICT(mal_id) DO UPDATE SET misses = 0, last_attempt_at = excluded.last_attempt_at")
+	} else {
+		queryBuilder = queryBuilder.Suffix("ON CONFLICT(mal_id) DO UPDATE SET misses = misses + 1, last_attempt_at = excluded.last_attempt_at")
+	}
+
+	query, args, err := queryBuilder.ToSql()
+	if err != nil {
+		return errors.Wrap(err, "error building query")
+	}
+
+	r.log.Trace().Str("query", query).Interface("args", args).Msg("RecordScrapeAttempt")
+
 	_, err = r.db.handler.ExecContext(ctx, query, args...)
 	if err != nil {
 		return errors.Wrap(err, "error executing query")
@@ -143,11 +320,23 @@ func (r *CacheRepo) GetTMDBIDs(ctx context.Context) (map[int]int, error) {
 	return result, nil
 }
 
-// UpsertTMDB inserts or updates a TMDB cache entry
-func (r *CacheRepo) UpsertTMDB(ctx context.Context, malID, tmdbID int) error {
+// UpsertTMDB inserts or updates a TMDB cache entry.
+// A mapping_history row is appended when the TMDB ID changes.
+func (r *CacheRepo) UpsertTMDB(ctx context.Context, malID, tmdbID int, provenance domain.Provenance) error {
 	now := time.Now().Format(time.RFC3339)
 	hasTmdbID := tmdbID > 0
 
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	oldID, err := r.getMappedID(ctx, tx, "tmdb_cache", "tmdb_id", malID)
+	if err != nil {
+		return err
+	}
+
 	queryBuilder := r.db.squirrel.
 		Replace("tmdb_cache").
 		Columns("mal_id", "tmdb_id", "has_tmdb_id", "cached_at", "last_used").
@@ -160,14 +349,106 @@ func (r *CacheRepo) UpsertTMDB(ctx context.Context, malID, tmdbID int) error {
 
 	r.log.Trace().Str("query", query).Interface("args", args).Msg("UpsertTMDB")
 
-	_, err = r.db.handler.ExecContext(ctx, query, args...)
+	_, err = tx.ExecContext(ctx, query, args...)
 	if err != nil {
 		return errors.Wrap(err, "error executing query")
 	}
 
+	if oldID != tmdbID {
+		if err := r.insertHistory(ctx, tx, malID, domain.MappingSourceTMDB, oldID, tmdbID, now, provenance); err != nil {
+			return err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return errors.Wrap(err, "error committing transaction")
+	}
+
 	return nil
 }
 
+// getMappedID returns the currently cached ID for a MAL ID, or 0 if there is none
+func (r *CacheRepo) getMappedID(ctx context.Context, tx *Tx, table, idColumn string, malID int) (int, error) {
+	queryBuilder := r.db.squirrel.
+		Select(idColumn).
+		From(table).
+		Where(sq.Eq{"mal_id": malID})
+
+	query, args, err := queryBuilder.ToSql()
+	if err != nil {
+		return 0, errors.Wrap(err, "error building query")
+	}
+
+	var id int
+	if err := tx.QueryRowContext(ctx, query, args...).Scan(&id); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, nil
+		}
+		return 0, errors.Wrap(err, "error scanning row")
+	}
+
+	return id, nil
+}
+
+// insertHistory appends an ID change to mapping_history
+func (r *CacheRepo) insertHistory(ctx context.Context, tx *Tx, malID int, source domain.MappingSource, oldID, newID int, changedAt string, provenance domain.Provenance) error {
+	queryBuilder := r.db.squirrel.
+		Insert("mapping_history").
+		Columns("mal_id", "source", "old_id", "new_id", "changed_at", "provenance").
+		Values(malID, source, oldID, newID, changedAt, provenance)
+
+	query, args, err := queryBuilder.ToSql()
+	if err != nil {
+		return errors.Wrap(err, "error building query")
+	}
+
+	r.log.Trace().Str("query", query).Interface("args", args).Msg("insertHistory")
+
+	_, err = tx.ExecContext(ctx, query, args...)
+	if err != nil {
+		return errors.Wrap(err, "error executing query")
+	}
+
+	return nil
+}
+
+// GetMappingHistory returns all recorded ID changes for a MAL ID, oldest first
+func (r *CacheRepo) GetMappingHistory(ctx context.Context, malID int) ([]*domain.MappingHistoryEntry, error) {
+	queryBuilder := r.db.squirrel.
+		Select("mal_id", "source", "old_id", "new_id", "changed_at", "provenance").
+		From("mapping_history").
+		Where(sq.Eq{"mal_id": malID}).
+		OrderBy("id ASC")
+
+	query, args, err := queryBuilder.ToSql()
+	if err != nil {
+		return nil, errors.Wrap(err, "error building query")
+	}
+
+	r.log.Trace().Str("query", query).Interface("args", args).Msg("GetMappingHistory")
+
+	rows, err := r.db.handler.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, errors.Wrap(err, "error executing query")
+	}
+	defer rows.Close()
+
+	var entries []*domain.MappingHistoryEntry
+	for rows.Next() {
+		entry := &domain.MappingHistoryEntry{}
+		if err := rows.Scan(&entry.MalID, &entry.Source, &entry.OldID, &entry.NewID, &entry.ChangedAt, &entry.Provenance); err != nil {
+			return nil, errors.Wrap(err, "error scanning row")
+		}
+		entries = append(entries, entry)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, errors.Wrap(err, "error iterating rows")
+	}
+
+	return entries, nil
+}
+
 // GetEntriesByReleaseYear returns cache entries for anime released in a specific year
 func (r *CacheRepo) GetEntriesByReleaseYear(ctx context.Context, year int) ([]*domain.MALCacheEntry, error) {
 	queryBuilder := r.db.squirrel.
@@ -216,22 +497,36 @@ func (r *CacheRepo) GetEntriesByReleaseYear(ctx context.Context, year int) ([]*d
 	return entries, nil
 }
 
-// DeleteMAL deletes a MAL cache entry (cascade deletes AniDB and TMDB entries)
+// DeleteMAL deletes a MAL cache entry and all rows referring to its MAL ID in one transaction.
+// Foreign keys are not enabled on the connection, so the dependent rows are deleted explicitly.
+// mapping_history is append-only and keeps its rows after the purge.
 func (r *CacheRepo) DeleteMAL(ctx context.Context, malID int) error {
-	queryBuilder := r.db.squirrel.
-		Delete("mal_cache").
-		Where(sq.Eq{"mal_id": malID})
-
-	query, args, err := queryBuilder.ToSql()
+	tx, err := r.db.BeginTx(ctx, nil)
 	if err != nil {
-		return errors.Wrap(err, "error building delete query")
+		return err
 	}
+	defer tx.Rollback()
 
-	r.log.Trace().Str("query", query).Interface("args", args).Msg("DeleteMAL")
+	// Dependent tables first, mal_cache last
+	for _, table := range []string{"anidb_cache", "tmdb_cache", "scrape_attempts", "mal_cache"} {
+		queryBuilder := r.db.squirrel.
+			Delete(table).
+			Where(sq.Eq{"mal_id": malID})
 
-	_, err = r.db.handler.ExecContext(ctx, query, args...)
-	if err != nil {
-		return errors.Wrap(err, "error executing delete query")
+		query, args, err := queryBuilder.ToSql()
+		if err != nil {
+			return errors.Wrap(err, "error building delete query")
+		}
+
+		r.log.Trace().Str("query", query).Interface("args", args).Msg("DeleteMAL")
+
+		if _, err := tx.ExecContext(ctx, query, args...); err != nil {
+			return errors.Wrapf(err, "error deleting from %s", table)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return errors.Wrap(err, "error committing transaction")
 	}
 
 	return nil
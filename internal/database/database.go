@@ -20,6 +20,7 @@ type DB struct {
 	log      zerolog.Logger
 	lock     sync.RWMutex
 	squirrel sq.StatementBuilderType
+	readOnly bool
 }
 
 // NewDB creates a new database connection following shinkro's pattern
@@ -61,6 +62,38 @@ func NewDB(dir string, log zerolog.Logger) (*DB, error) {
 	return db, nil
 }
 
+// NewReadOnlyDB opens an existing database without writing to it, e.g. for dry runs.
+// The schema must already be up to date since migrations are not applied.
+func NewReadOnlyDB(dir string, log zerolog.Logger) (*DB, error) {
+	db := &DB{
+		log:      log.With().Str("module", "database").Logger(),
+		squirrel: sq.StatementBuilder.PlaceholderFormat(sq.Dollar),
+		readOnly: true,
+	}
+
+	var err error
+
+	DSN := "file:" + filepath.Join(dir, "shinkrodb.db") + "?mode=ro&_pragma=busy_timeout(1000)"
+
+	db.handler, err = sql.Open("sqlite", DSN)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to connect to database")
+	}
+
+	var version int
+	if err := db.handler.QueryRow("PRAGMA user_version").Scan(&version); err != nil {
+		db.handler.Close()
+		return nil, errors.Wrap(err, "failed to query schema version")
+	}
+
+	if version != len(cacheMigrations) {
+		db.handler.Close()
+		return nil, errors.Errorf("cache database schema version (%d) does not match the supported version (%d), run shinkrodb run first to migrate it", version, len(cacheMigrations))
+	}
+
+	return db, nil
+}
+
 // Migrate handles database schema creation and migrations using versioning
 // Follows the same pattern as shinkro's database migration strategy
 func (db *DB) Migrate() error {
@@ -117,6 +150,10 @@ func (db *DB) Migrate() error {
 
 // Close closes the database connection
 func (db *DB) Close() error {
+	if db.readOnly {
+		return db.handler.Close()
+	}
+
 	if _, err := db.handler.Exec(`PRAGMA optimize;`); err != nil {
 		return errors.Wrap(err, "query planner optimization")
 	}
@@ -7,8 +7,13 @@ CREATE TABLE mal_cache (
 	url TEXT NOT NULL,
 	release_date TEXT,
 	type TEXT,
+	status TEXT,
+	episodes INTEGER,
+	japanese_title TEXT,
+	synonyms TEXT,
 	cached_at TIMESTAMP NOT NULL,
 	last_used TIMESTAMP NOT NULL,
+	tombstoned_at TIMESTAMP,
 	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
 );
 
@@ -16,6 +21,7 @@ CREATE INDEX idx_mal_cached_at ON mal_cache(cached_at);
 CREATE INDEX idx_mal_last_used ON mal_cache(last_used);
 CREATE INDEX idx_mal_release_date ON mal_cache(release_date);
 CREATE INDEX idx_mal_type ON mal_cache(type);
+CREATE INDEX idx_mal_tombstoned_at ON mal_cache(tombstoned_at);
 
 -- AniDB cache table
 CREATE TABLE anidb_cache (
@@ -42,6 +48,51 @@ CREATE TABLE tmdb_cache (
 
 CREATE INDEX idx_tmdb_id ON tmdb_cache(tmdb_id);
 CREATE INDEX idx_tmdb_cached_at ON tmdb_cache(cached_at);
+
+-- Append-only history of ID changes written by the cache upserts
+CREATE TABLE mapping_history (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	mal_id INTEGER NOT NULL,
+	source TEXT NOT NULL,
+	old_id INTEGER NOT NULL,
+	new_id INTEGER NOT NULL,
+	changed_at TIMESTAMP NOT NULL,
+	provenance TEXT NOT NULL
+);
+
+CREATE INDEX idx_mapping_history_mal_id ON mapping_history(mal_id);
+
+-- AniDB scrape attempts, used by the scrape policy for backoff and budgeting
+CREATE TABLE scrape_attempts (
+	mal_id INTEGER PRIMARY KEY,
+	misses INTEGER NOT NULL DEFAULT 0,
+	last_attempt_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX idx_scrape_attempts_last_attempt_at ON scrape_attempts(last_attempt_at);
+
+-- Anime lists written by each pipeline stage (anime_store = "sqlite"), in stage output order
+CREATE TABLE anime_stage (
+	stage TEXT NOT NULL,
+	position INTEGER NOT NULL,
+	mal_id INTEGER NOT NULL,
+	title TEXT NOT NULL,
+	en_title TEXT,
+	anidb_id INTEGER,
+	tvdb_id INTEGER,
+	tmdb_id INTEGER,
+	type TEXT NOT NULL,
+	release_date TEXT,
+	PRIMARY KEY (stage, position)
+);
+
+CREATE INDEX idx_anime_stage_mal_id ON anime_stage(stage, mal_id);
+
+CREATE TABLE anime_stage_runs (
+	stage TEXT PRIMARY KEY,
+	count INTEGER NOT NULL,
+	stored_at TIMESTAMP NOT NULL
+);
 `
 
 // cacheMigrations contains incremental schema changes
@@ -49,4 +100,57 @@ CREATE INDEX idx_tmdb_cached_at ON tmdb_cache(cached_at);
 // cacheMigrations[0] is empty because version 0 uses the base schema
 var cacheMigrations = []string{
 	"",
+	// Version 2: track MAL IDs that disappeared from the ranking
+	`ALTER TABLE mal_cache ADD COLUMN tombstoned_at TIMESTAMP;
+
+	CREATE INDEX idx_mal_tombstoned_at ON mal_cache(tombstoned_at);`,
+	// Version 3: append-only history of AniDB/TMDB ID changes
+	`CREATE TABLE mapping_history (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		mal_id INTEGER NOT NULL,
+		source TEXT NOT NULL,
+		old_id INTEGER NOT NULL,
+		new_id INTEGER NOT NULL,
+		changed_at TIMESTAMP NOT NULL,
+		provenance TEXT NOT NULL
+	);
+
+	CREATE INDEX idx_mapping_history_mal_id ON mapping_history(mal_id);`,
+	// Version 4: airing status and AniDB scrape attempts for the scrape policy
+	`ALTER TABLE mal_cache ADD COLUMN status TEXT;
+
+	CREATE TABLE scrape_attempts (
+		mal_id INTEGER PRIMARY KEY,
+		misses INTEGER NOT NULL DEFAULT 0,
+		last_attempt_at TIMESTAMP NOT NULL
+	);
+
+	CREATE INDEX idx_scrape_attempts_last_attempt_at ON scrape_attempts(last_attempt_at);`,
+	// Version 5: anime lists of the pipeline stages for the sqlite anime store
+	`CREATE TABLE anime_stage (
+		stage TEXT NOT NULL,
+		position INTEGER NOT NULL,
+		mal_id INTEGER NOT NULL,
+		title TEXT NOT NULL,
+		en_title TEXT,
+		anidb_id INTEGER,
+		tvdb_id INTEGER,
+		tmdb_id INTEGER,
+		type TEXT NOT NULL,
+		release_date TEXT,
+		PRIMARY KEY (stage, position)
+	);
+
+	CREATE INDEX idx_anime_stage_mal_id ON anime_stage(stage, mal_id);
+
+	CREATE TABLE anime_stage_runs (
+		stage TEXT PRIMARY KEY,
+		count INTEGER NOT NULL,
+		stored_at TIMESTAMP NOT NULL
+	);`,
+	// Version 6: MAL episode counts for the dedupe rules
+	`ALTER TABLE mal_cache ADD COLUMN episodes INTEGER;`,
+	// Version 7: MAL Japanese titles and synonyms (JSON array) for the dedupe title rule
+	`ALTER TABLE mal_cache ADD COLUMN japanese_title TEXT;
+	ALTER TABLE mal_cache ADD COLUMN synonyms TEXT;`,
 }
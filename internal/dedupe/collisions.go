@@ -0,0 +1,199 @@
+package dedupe
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/pkg/errors"
+	"github.com/varoOP/shinkrodb/internal/domain"
+)
+
+// tvdbKey is a TVDB ID and season, the key shinkro looks TV entries up by
+type tvdbKey struct {
+	id     int
+	season int
+}
+
+// CheckCollisions finds entries of any type sharing a TMDB ID, or a TVDB ID and season, and applies the
+// collision policy to them; entries listed in the override file for the shared ID are kept regardless.
+// Entries without a known season are left out of the TVDB collisions, season returns it for an entry.
+func (s *service) CheckCollisions(ctx context.Context, anime []domain.Anime, season func(domain.Anime) *int) (*domain.DedupeReport, []domain.Anime, error) {
+	report := &domain.DedupeReport{Groups: []domain.DedupeGroup{}}
+
+	overrides := &overrideFile{}
+	if s.config.DedupeOverrideFile != "" {
+		var err error
+		overrides, err = loadOverrides(s.config.DedupeOverrideFile)
+		if err != nil {
+			return nil, nil, errors.Wrap(err, "failed to load dedupe override file")
+		}
+	}
+
+	// TMDB collisions, movies are the expected type
+	tmdbToIndices := make(map[int][]int)
+	for i, a := range anime {
+		if a.TmdbID > 0 {
+			tmdbToIndices[a.TmdbID] = append(tmdbToIndices[a.TmdbID], i)
+		}
+	}
+
+	indicesToRemove := make(map[int]bool)
+	for _, tmdbID := range collidingKeys(tmdbToIndices, func(a, b int) bool { return a < b }) {
+		group := s.resolveCollision(domain.DedupeGroup{Key: domain.DedupeKeyTMDB, TmdbID: tmdbID}, pick(anime, tmdbToIndices[tmdbID]), overrides.TMDB[tmdbID], "movie")
+		report.Groups = append(report.Groups, group)
+		markRemoved(indicesToRemove, group, tmdbToIndices[tmdbID])
+	}
+
+	// TVDB collisions between the entries left, tv is the expected type
+	tvdbToIndices := make(map[tvdbKey][]int)
+	for i, a := range anime {
+		if a.TvdbID <= 0 || indicesToRemove[i] {
+			continue
+		}
+		if season := season(a); season != nil {
+			key := tvdbKey{id: a.TvdbID, season: *season}
+			tvdbToIndices[key] = append(tvdbToIndices[key], i)
+		}
+	}
+
+	less := func(a, b tvdbKey) bool { return a.id < b.id || (a.id == b.id && a.season < b.season) }
+	for _, key := range collidingKeys(tvdbToIndices, less) {
+		season := key.season
+		group := s.resolveCollision(domain.DedupeGroup{Key: domain.DedupeKeyTVDB, TvdbID: key.id, Season: &season}, pick(anime, tvdbToIndices[key]), overrides.TVDB[key.id][key.season], "tv")
+		report.Groups = append(report.Groups, group)
+		markRemoved(indicesToRemove, group, tvdbToIndices[key])
+	}
+
+	if len(report.Groups) > 0 {
+		s.log.Info().
+			Int("collisions", len(report.Groups)).
+			Int("entries_to_remove", len(indicesToRemove)).
+			Str("policy", string(s.config.CollisionPolicy)).
+			Msg("Found TMDB/TVDB collisions")
+	}
+
+	if len(indicesToRemove) == 0 {
+		return report, anime, nil
+	}
+
+	deduped := make([]domain.Anime, 0, len(anime)-len(indicesToRemove))
+	for i := range anime {
+		if !indicesToRemove[i] {
+			deduped = append(deduped, anime[i])
+		}
+	}
+
+	return report, deduped, nil
+}
+
+// resolveCollision applies the override or the collision policy to the candidates sharing the key of group
+func (s *service) resolveCollision(group domain.DedupeGroup, candidates []domain.Anime, override []int, preferredType string) domain.DedupeGroup {
+	keep := make(map[int]bool, len(candidates))
+
+	if kept := filterKept(candidates, override); len(kept) > 0 {
+		for _, a := range kept {
+			keep[a.MalID] = true
+		}
+		group.Decision = domain.DedupeResolved
+		group.Rules = []string{domain.DedupeRuleOverride}
+		group.Reason = overrideReason(override)
+	} else {
+		switch s.config.CollisionPolicy {
+		case domain.CollisionKeepBest:
+			best, reason := bestEntry(candidates, preferredType)
+			keep[best.MalID] = true
+			group.Decision = domain.DedupeResolved
+			group.Rules = []string{string(domain.CollisionKeepBest)}
+			group.Reason = reason
+
+		case domain.CollisionDropAll:
+			group.Decision = domain.DedupeDropAll
+			group.Reason = "collision policy drops every entry"
+
+		default:
+			for _, a := range candidates {
+				keep[a.MalID] = true
+			}
+			group.Decision = domain.DedupeKeepAll
+			group.Reason = "collision policy keeps every entry"
+		}
+	}
+
+	for _, a := range candidates {
+		group.Entries = append(group.Entries, domain.DedupeEntry{MalID: a.MalID, Title: a.MainTitle, Kept: keep[a.MalID]})
+	}
+
+	for _, entry := range group.Entries {
+		s.log.Debug().
+			Str("key", string(group.Key)).
+			Int("tmdb_id", group.TmdbID).
+			Int("tvdb_id", group.TvdbID).
+			Int("mal_id", entry.MalID).
+			Str("anime_title", entry.Title).
+			Bool("kept", entry.Kept).
+			Str("reason", group.Reason).
+			Msg("Collision decision")
+	}
+
+	return group
+}
+
+// bestEntry picks the entry of the preferred type, then the earliest release, then the lowest MAL ID,
+// and describes what set it apart from the runner-up
+func bestEntry(candidates []domain.Anime, preferredType string) (domain.Anime, string) {
+	sorted := append([]domain.Anime(nil), candidates...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		a, b := sorted[i], sorted[j]
+		if (a.Type == preferredType) != (b.Type == preferredType) {
+			return a.Type == preferredType
+		}
+		if a.ReleaseDate != b.ReleaseDate {
+			// Unknown release dates sort last
+			return b.ReleaseDate == "" || (a.ReleaseDate != "" && a.ReleaseDate < b.ReleaseDate)
+		}
+		return a.MalID < b.MalID
+	})
+
+	best, next := sorted[0], sorted[1]
+	switch {
+	case (best.Type == preferredType) != (next.Type == preferredType):
+		return best, fmt.Sprintf("best entry is the only one of type %s", preferredType)
+	case best.ReleaseDate != next.ReleaseDate:
+		return best, fmt.Sprintf("best entry has the earliest release date %s", best.ReleaseDate)
+	default:
+		return best, "best entry has the lowest MAL ID"
+	}
+}
+
+// collidingKeys returns the keys mapped to more than one entry, sorted by less
+func collidingKeys[K comparable](indices map[K][]int, less func(a, b K) bool) []K {
+	keys := []K{}
+	for key, entries := range indices {
+		if len(entries) > 1 {
+			keys = append(keys, key)
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool { return less(keys[i], keys[j]) })
+
+	return keys
+}
+
+// pick returns the anime at indices
+func pick(anime []domain.Anime, indices []int) []domain.Anime {
+	picked := make([]domain.Anime, 0, len(indices))
+	for _, index := range indices {
+		picked = append(picked, anime[index])
+	}
+
+	return picked
+}
+
+// markRemoved marks the indices of the entries the group did not keep
+func markRemoved(indicesToRemove map[int]bool, group domain.DedupeGroup, indices []int) {
+	for i, entry := range group.Entries {
+		if !entry.Kept {
+			indicesToRemove[indices[i]] = true
+		}
+	}
+}
@@ -0,0 +1,196 @@
+package dedupe
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/varoOP/shinkrodb/internal/domain"
+	"gopkg.in/yaml.v3"
+)
+
+// titleScores ranks the AniDB title types, a match with the main title beats an official title or a synonym
+var titleScores = map[string]int{
+	"main":     3,
+	"official": 2,
+	"syn":      1,
+	"short":    1,
+}
+
+// titleRule keeps the entries whose title best matches an AniDB title.
+// Titles are compared normalised: case, punctuation, diacritics and long vowel spellings are ignored.
+type titleRule struct{}
+
+func (r *titleRule) Name() string { return domain.DedupeRuleTitle }
+
+func (r *titleRule) Resolve(anidb *domain.AniDBAnime, candidates []domain.Anime) ([]int, string) {
+	best := 0
+	var bestTitle domain.AniDBTitle
+	scores := make(map[int]int, len(candidates))
+	for _, a := range candidates {
+		names := append([]string{a.MainTitle, a.EnglishTitle, a.JapaneseTitle}, a.Synonyms...)
+		for _, title := range anidb.Titles {
+			normalized := normalizeTitle(title.Text)
+			if normalized == "" {
+				continue
+			}
+			for _, name := range names {
+				if normalizeTitle(name) != normalized || titleScores[title.Type] <= scores[a.MalID] {
+					continue
+				}
+				scores[a.MalID] = titleScores[title.Type]
+				if scores[a.MalID] > best {
+					best, bestTitle = scores[a.MalID], title
+				}
+			}
+		}
+	}
+
+	if best == 0 {
+		return nil, ""
+	}
+
+	keep := []int{}
+	for _, a := range candidates {
+		if scores[a.MalID] == best {
+			keep = append(keep, a.MalID)
+		}
+	}
+
+	return keep, fmt.Sprintf("title matches AniDB %s title %q (%s)", bestTitle.Type, bestTitle.Text, bestTitle.Lang)
+}
+
+// startDateRule keeps the entries whose release date matches the AniDB start date,
+// or its month if no entry matches exactly
+type startDateRule struct{}
+
+func (r *startDateRule) Name() string { return domain.DedupeRuleStartDate }
+
+func (r *startDateRule) Resolve(anidb *domain.AniDBAnime, candidates []domain.Anime) ([]int, string) {
+	if anidb.StartDate == "" {
+		return nil, ""
+	}
+
+	// Exact dates first, then year-month
+	for _, length := range []int{len("2006-01-02"), len("2006-01")} {
+		if len(anidb.StartDate) < length {
+			continue
+		}
+		start := anidb.StartDate[:length]
+
+		keep := []int{}
+		for _, a := range candidates {
+			if len(a.ReleaseDate) >= length && a.ReleaseDate[:length] == start {
+				keep = append(keep, a.MalID)
+			}
+		}
+		if len(keep) > 0 {
+			return keep, fmt.Sprintf("release date matches AniDB start date %s", start)
+		}
+	}
+
+	return nil, ""
+}
+
+// episodesRule keeps the entries whose MAL episode count is closest to the AniDB episode count
+type episodesRule struct{}
+
+func (r *episodesRule) Name() string { return domain.DedupeRuleEpisodes }
+
+func (r *episodesRule) Resolve(anidb *domain.AniDBAnime, candidates []domain.Anime) ([]int, string) {
+	if anidb.Episodes == 0 {
+		return nil, ""
+	}
+
+	best := -1
+	keep := []int{}
+	for _, a := range candidates {
+		if a.Episodes == 0 {
+			continue
+		}
+
+		diff := a.Episodes - anidb.Episodes
+		if diff < 0 {
+			diff = -diff
+		}
+
+		switch {
+		case best == -1 || diff < best:
+			best = diff
+			keep = []int{a.MalID}
+		case diff == best:
+			keep = append(keep, a.MalID)
+		}
+	}
+
+	if best == -1 {
+		return nil, ""
+	}
+
+	return keep, fmt.Sprintf("episode count closest to AniDB's %d episodes (off by %d)", anidb.Episodes, best)
+}
+
+// overrideRule keeps the MAL IDs a curator listed for an AniDB ID in the override file
+type overrideRule struct {
+	keep map[int][]int
+}
+
+// overrideFile is the content of the dedupe override file, the MAL IDs to keep by AniDB ID,
+// by TMDB ID and by TVDB ID and season:
+//
+//	anidb:
+//	  23: [1]
+//	tmdb:
+//	  8392: [199]
+//	tvdb:
+//	  76885:
+//	    1: [1]
+type overrideFile struct {
+	AniDB map[int][]int         `yaml:"anidb"`
+	TMDB  map[int][]int         `yaml:"tmdb"`
+	TVDB  map[int]map[int][]int `yaml:"tvdb"`
+}
+
+func loadOverrides(path string) (*overrideFile, error) {
+	body, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	file := &overrideFile{}
+	if err := yaml.Unmarshal(body, file); err != nil {
+		return nil, err
+	}
+
+	return file, nil
+}
+
+func newOverrideRule(path string) (*overrideRule, error) {
+	file, err := loadOverrides(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return &overrideRule{keep: file.AniDB}, nil
+}
+
+func (r *overrideRule) Name() string { return domain.DedupeRuleOverride }
+
+func (r *overrideRule) Resolve(anidb *domain.AniDBAnime, candidates []domain.Anime) ([]int, string) {
+	keep, ok := r.keep[anidb.ID]
+	if !ok {
+		return nil, ""
+	}
+
+	return keep, overrideReason(keep)
+}
+
+// overrideReason describes the MAL IDs kept by an override
+func overrideReason(keep []int) string {
+	ids := make([]string, 0, len(keep))
+	for _, malID := range keep {
+		ids = append(ids, fmt.Sprint(malID))
+	}
+
+	return fmt.Sprintf("override keeps MAL ID %s", strings.Join(ids, ", "))
+}
@@ -2,10 +2,8 @@ package dedupe
 
 import (
 	"context"
-	"encoding/xml"
-	"fmt"
-	"net/http"
-	"strconv"
+	"iter"
+	"sort"
 	"strings"
 
 	"github.com/pkg/errors"
@@ -14,24 +12,80 @@ import (
 )
 
 type Service interface {
-	CheckDupes(ctx context.Context, anime []domain.Anime) (int, []domain.Anime, error)
+	// Candidates streams anime twice and returns the entries sharing a key with another entry, in order.
+	// Only these can be duplicates or collisions, so the checks below can run on them instead of the whole list.
+	Candidates(ctx context.Context, anime iter.Seq2[domain.Anime, error]) ([]domain.Anime, error)
+	// CheckDupes resolves the tv entries sharing an AniDB ID with the configured rules.
+	// It returns the report of all duplicate groups and the anime without the removed entries.
+	CheckDupes(ctx context.Context, anime []domain.Anime) (*domain.DedupeReport, []domain.Anime, error)
+	// CheckCollisions applies the collision policy to entries sharing a TMDB ID or a TVDB ID and season.
+	// season returns the TVDB season of an entry, nil if it is not known.
+	CheckCollisions(ctx context.Context, anime []domain.Anime, season func(domain.Anime) *int) (*domain.DedupeReport, []domain.Anime, error)
+	// StoreReport writes the report to the JSON and YAML dupes files, replacing the previous run's
+	StoreReport(ctx context.Context, report *domain.DedupeReport) error
 }
 
 type service struct {
-	log         zerolog.Logger
-	animeRepo   domain.AnimeRepository
-	aidTitleMap map[int]string
+	log            zerolog.Logger
+	config         *domain.Config
+	animeRepo      domain.AnimeRepository
+	reportRepo     domain.DedupeReportRepository
+	reportJSONPath string
+	reportYAMLPath string
 }
 
-func NewService(log zerolog.Logger, animeRepo domain.AnimeRepository) Service {
+// NewService creates a dedupe service that stores its reports at the given paths
+func NewService(log zerolog.Logger, config *domain.Config, animeRepo domain.AnimeRepository, reportRepo domain.DedupeReportRepository, reportJSONPath, reportYAMLPath string) Service {
 	return &service{
-		log:         log.With().Str("module", "dedupe").Logger(),
-		animeRepo:   animeRepo,
-		aidTitleMap: make(map[int]string),
+		log:            log.With().Str("module", "dedupe").Logger(),
+		config:         config,
+		animeRepo:      animeRepo,
+		reportRepo:     reportRepo,
+		reportJSONPath: reportJSONPath,
+		reportYAMLPath: reportYAMLPath,
 	}
 }
 
-func (s *service) CheckDupes(ctx context.Context, anime []domain.Anime) (int, []domain.Anime, error) {
+// Candidates returns the entries of anime sharing an AniDB ID, a TMDB ID or a TVDB ID with another entry.
+// The keys are counted in a first pass, so only these entries are kept in memory.
+func (s *service) Candidates(ctx context.Context, anime iter.Seq2[domain.Anime, error]) ([]domain.Anime, error) {
+	anidbIDs := make(map[int]int)
+	tmdbIDs := make(map[int]int)
+	tvdbIDs := make(map[int]int)
+	for a, err := range anime {
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to get anime list")
+		}
+
+		// Same keys as CheckDupes and CheckCollisions, the TVDB season is checked by CheckCollisions
+		if a.AnidbID > 0 && a.Type == "tv" {
+			anidbIDs[a.AnidbID]++
+		}
+		if a.TmdbID > 0 {
+			tmdbIDs[a.TmdbID]++
+		}
+		if a.TvdbID > 0 {
+			tvdbIDs[a.TvdbID]++
+		}
+	}
+
+	candidates := []domain.Anime{}
+	for a, err := range anime {
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to get anime list")
+		}
+
+		if anidbIDs[a.AnidbID] > 1 || tmdbIDs[a.TmdbID] > 1 || tvdbIDs[a.TvdbID] > 1 {
+			candidates = append(candidates, a)
+		}
+	}
+
+	return candidates, nil
+}
+
+func (s *service) CheckDupes(ctx context.Context, anime []domain.Anime) (*domain.DedupeReport, []domain.Anime, error) {
+	report := &domain.DedupeReport{Groups: []domain.DedupeGroup{}}
+
 	// Build map: AniDB ID -> []indices for O(1) duplicate detection
 	// Only consider entries with AniDB ID and type "tv"
 	anidbToIndices := make(map[int][]int)
@@ -41,55 +95,70 @@ func (s *service) CheckDupes(ctx context.Context, anime []domain.Anime) (int, []
 		}
 	}
 
-	// Find AniDB IDs with duplicates (more than one entry)
-	duplicateAnidbIDs := make(map[int]bool)
+	// Find AniDB IDs with duplicates (more than one entry), in a stable order for the report
+	duplicateAnidbIDs := []int{}
 	for anidbID, indices := range anidbToIndices {
 		if len(indices) > 1 {
-			duplicateAnidbIDs[anidbID] = true
-			// Initialize title map entry
-			if _, ok := s.aidTitleMap[anidbID]; !ok {
-				s.aidTitleMap[anidbID] = ""
-			}
+			duplicateAnidbIDs = append(duplicateAnidbIDs, anidbID)
 		}
 	}
+	sort.Ints(duplicateAnidbIDs)
 
 	if len(duplicateAnidbIDs) == 0 {
-		return 0, anime, nil
+		return report, anime, nil
 	}
 
-	// Fill title map for all duplicate AniDB IDs
-	if err := s.fillAidTitleMap(ctx); err != nil {
-		s.log.Warn().Err(err).Msg("failed to fill AniDB title map")
-		// Continue with empty titles - will keep all entries
+	rules, err := s.rules()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	titles, err := s.fetchAnimeTitles(ctx)
+	if err != nil {
+		// Offline runs only use cached files, a missing cache is not silently ignored
+		if s.config.Offline {
+			return nil, nil, err
+		}
+		s.log.Warn().Err(err).Msg("failed to fetch AniDB titles")
+		// Continue without titles - the title rule keeps all entries
+	}
+
+	var metadata map[int]anidbMetadata
+	if s.config.AniDBMetadataFile != "" {
+		metadata, err = loadMetadata(s.config.AniDBMetadataFile)
+		if err != nil {
+			return nil, nil, err
+		}
 	}
 
 	// Build set of indices to remove (more efficient than recursive calls)
 	indicesToRemove := make(map[int]bool)
 
 	// Check each duplicate group
-	for anidbID := range duplicateAnidbIDs {
-		mainTitle := s.aidTitleMap[anidbID]
-		if mainTitle == "" {
-			// No title found, can't determine which to remove - keep all
-			continue
+	for _, anidbID := range duplicateAnidbIDs {
+		info := &domain.AniDBAnime{ID: anidbID, Titles: titles[anidbID]}
+		if m, ok := metadata[anidbID]; ok {
+			info.StartDate = m.StartDate
+			info.Episodes = m.Episodes
 		}
 
-		// Find entries that don't match the AniDB main title
+		candidates := make([]domain.Anime, 0, len(anidbToIndices[anidbID]))
 		for _, index := range anidbToIndices[anidbID] {
-			if !strings.EqualFold(anime[index].MainTitle, mainTitle) {
-				indicesToRemove[index] = true
-				s.log.Debug().
-					Int("mal_id", anime[index].MalID).
-					Str("anime_title", anime[index].MainTitle).
-					Str("anidb_title", mainTitle).
-					Int("anidb_id", anidbID).
-					Msg("Marking non-matching entry for removal")
+			candidates = append(candidates, anime[index])
+		}
+
+		group := s.resolve(rules, info, candidates)
+		report.Groups = append(report.Groups, group)
+
+		for i, entry := range group.Entries {
+			if !entry.Kept {
+				indicesToRemove[anidbToIndices[anidbID][i]] = true
 			}
 		}
 	}
 
 	if len(indicesToRemove) == 0 {
-		return len(duplicateAnidbIDs), anime, nil
+		return report, anime, nil
 	}
 
 	s.log.Info().
@@ -105,72 +174,122 @@ func (s *service) CheckDupes(ctx context.Context, anime []domain.Anime) (int, []
 		}
 	}
 
-	return len(duplicateAnidbIDs), deduped, nil
+	return report, deduped, nil
 }
 
+func (s *service) StoreReport(ctx context.Context, report *domain.DedupeReport) error {
+	for _, path := range []string{s.reportJSONPath, s.reportYAMLPath} {
+		if err := s.reportRepo.StoreDedupeReport(ctx, path, report); err != nil {
+			return errors.Wrapf(err, "failed to store dedupe report %s", path)
+		}
+	}
 
-func (s *service) fillAidTitleMap(ctx context.Context) error {
-	anidb := &Animetitles{}
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "https://github.com/Anime-Lists/anime-lists/raw/master/animetitles.xml", nil)
-	if err != nil {
-		return errors.Wrap(err, "failed to create request")
+	s.log.Info().
+		Int("groups", len(report.Groups)).
+		Int("removed", report.Removed()).
+		Str("path", s.reportJSONPath).
+		Msg("Stored dedupe report")
+	return nil
+}
+
+// resolve runs the rules over a duplicate group. Each rule narrows the candidates down further,
+// until a single entry is left or the rules are exhausted; the override rule is final.
+func (s *service) resolve(rules []domain.DedupeRule, info *domain.AniDBAnime, candidates []domain.Anime) domain.DedupeGroup {
+	group := domain.DedupeGroup{
+		Key:        domain.DedupeKeyAniDB,
+		AnidbID:    info.ID,
+		AnidbTitle: info.MainTitle(),
+		Decision:   domain.DedupeKeepAll,
 	}
 
-	client := &http.Client{}
-	resp, err := client.Do(req)
-	if err != nil {
-		return errors.Wrap(err, "failed to fetch animetitles.xml")
+	remaining := candidates
+	reasons := []string{}
+	for _, rule := range rules {
+		keep, reason := rule.Resolve(info, remaining)
+		kept := filterKept(remaining, keep)
+		// a curator override is final even when it keeps every candidate
+		override := rule.Name() == domain.DedupeRuleOverride && len(kept) > 0
+		if !override && (len(kept) == 0 || len(kept) == len(remaining)) {
+			s.log.Trace().Int("anidb_id", info.ID).Str("rule", rule.Name()).Msg("rule could not decide")
+			continue
+		}
+
+		remaining = kept
+		group.Rules = append(group.Rules, rule.Name())
+		reasons = append(reasons, reason)
+
+		if len(remaining) == 1 || override {
+			break
+		}
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("unexpected status code %d", resp.StatusCode)
+	keep := make(map[int]bool, len(remaining))
+	for _, a := range remaining {
+		keep[a.MalID] = true
 	}
 
-	xr := xml.NewDecoder(resp.Body)
-	err = xr.Decode(anidb)
-	if err != nil {
-		return errors.Wrap(err, "failed to decode XML")
+	for _, a := range candidates {
+		group.Entries = append(group.Entries, domain.DedupeEntry{MalID: a.MalID, Title: a.MainTitle, Kept: keep[a.MalID]})
 	}
 
-	// Build a map of AniDB ID -> main title for O(1) lookup
-	anidbTitleMap := make(map[int]string)
-	for _, anime := range anidb.Anime {
-		aid, err := strconv.Atoi(anime.Aid)
-		if err != nil {
-			continue // Skip invalid AniDB IDs
-		}
+	if len(group.Rules) == 0 {
+		group.Reason = "no rule could tell the entries apart"
+	} else {
+		group.Decision = domain.DedupeResolved
+		group.Reason = strings.Join(reasons, "; ")
+	}
+
+	for _, entry := range group.Entries {
+		s.log.Debug().
+			Int("mal_id", entry.MalID).
+			Str("anime_title", entry.Title).
+			Str("anidb_title", group.AnidbTitle).
+			Int("anidb_id", group.AnidbID).
+			Bool("kept", entry.Kept).
+			Strs("rules", group.Rules).
+			Str("reason", group.Reason).
+			Msg("Dedupe decision")
+	}
+
+	return group
+}
 
-		// Find main title
-		for _, title := range anime.Title {
-			if title.Type == "main" {
-				anidbTitleMap[aid] = title.Text
+// filterKept returns the candidates whose MAL ID is in keep
+func filterKept(candidates []domain.Anime, keep []int) []domain.Anime {
+	kept := []domain.Anime{}
+	for _, a := range candidates {
+		for _, malID := range keep {
+			if a.MalID == malID {
+				kept = append(kept, a)
 				break
 			}
 		}
 	}
 
-	// Fill requested AniDB IDs from the map
-	for key := range s.aidTitleMap {
-		if s.aidTitleMap[key] != "" {
-			continue // Already filled
-		}
-		if title, found := anidbTitleMap[key]; found {
-			s.aidTitleMap[key] = title
+	return kept
+}
+
+// rules builds the dedupe rules in the order configured by dedupe_rules
+func (s *service) rules() ([]domain.DedupeRule, error) {
+	rules := make([]domain.DedupeRule, 0, len(s.config.DedupeRules))
+	for _, name := range s.config.DedupeRules {
+		switch name {
+		case domain.DedupeRuleOverride:
+			rule, err := newOverrideRule(s.config.DedupeOverrideFile)
+			if err != nil {
+				return nil, errors.Wrap(err, "failed to load dedupe override file")
+			}
+			rules = append(rules, rule)
+		case domain.DedupeRuleTitle:
+			rules = append(rules, &titleRule{})
+		case domain.DedupeRuleStartDate:
+			rules = append(rules, &startDateRule{})
+		case domain.DedupeRuleEpisodes:
+			rules = append(rules, &episodesRule{})
+		default:
+			s.log.Warn().Str("rule", name).Msg("unknown dedupe rule, skipping")
 		}
 	}
 
-	return nil
-}
-
-type Animetitles struct {
-	XMLName xml.Name `xml:"animetitles"`
-	Anime   []struct {
-		Aid   string `xml:"aid,attr"`
-		Title []struct {
-			Text string `xml:",chardata"`
-			Type string `xml:"type,attr"`
-			Lang string `xml:"lang,attr"`
-		} `xml:"title"`
-	} `xml:"anime"`
+	return rules, nil
 }
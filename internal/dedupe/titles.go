@@ -0,0 +1,141 @@
+package dedupe
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/pkg/errors"
+	"github.com/varoOP/shinkrodb/internal/domain"
+	"github.com/varoOP/shinkrodb/pkg/remotefile"
+	"golang.org/x/text/unicode/norm"
+)
+
+// titleLanguages are the AniDB title languages compared by the title rule
+var titleLanguages = map[string]bool{"x-jat": true, "en": true, "ja": true}
+
+const (
+	animeTitlesURL = "https://github.com/Anime-Lists/anime-lists/raw/master/animetitles.xml"
+	// animetitles.xml is cached in the current directory (./) like anime-list.xml
+	animeTitlesCachePath = "animetitles.xml"
+	animeTitlesMaxAge    = 24 * time.Hour
+)
+
+// fetchAnimeTitles gets animetitles.xml, revalidated once a day, and returns the titles of each AniDB ID.
+// Main titles are always included, other titles only in the title languages.
+func (s *service) fetchAnimeTitles(ctx context.Context) (map[int][]domain.AniDBTitle, error) {
+	file := &remotefile.File{
+		URL:     animeTitlesURL,
+		Path:    animeTitlesCachePath,
+		MaxAge:  animeTitlesMaxAge,
+		Offline: s.config.Offline,
+		OnStale: func(err error) {
+			s.log.Warn().Err(err).Msg("failed to refresh animetitles.xml, using the stale cached copy")
+		},
+	}
+
+	body, err := file.Get(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get animetitles.xml")
+	}
+
+	anidb := &Animetitles{}
+	if err := xml.Unmarshal(body, anidb); err != nil {
+		return nil, errors.Wrap(err, "failed to decode XML")
+	}
+
+	// Build a map of AniDB ID -> titles for O(1) lookup
+	titles := make(map[int][]domain.AniDBTitle)
+	for _, anime := range anidb.Anime {
+		aid, err := strconv.Atoi(anime.Aid)
+		if err != nil {
+			continue // Skip invalid AniDB IDs
+		}
+
+		for _, title := range anime.Title {
+			if title.Type != "main" && !titleLanguages[title.Lang] {
+				continue
+			}
+			titles[aid] = append(titles[aid], domain.AniDBTitle{Text: title.Text, Type: title.Type, Lang: title.Lang})
+		}
+	}
+
+	return titles, nil
+}
+
+type Animetitles struct {
+	XMLName xml.Name `xml:"animetitles"`
+	Anime   []struct {
+		Aid   string `xml:"aid,attr"`
+		Title []struct {
+			Text string `xml:",chardata"`
+			Type string `xml:"type,attr"`
+			Lang string `xml:"lang,attr"`
+		} `xml:"title"`
+	} `xml:"anime"`
+}
+
+// romanisation folds the common spellings of long vowels in romanised Japanese,
+// so e.g. "Shoujo", "Shojo" and "Shōjo" compare equal
+var romanisation = strings.NewReplacer("ou", "o", "oo", "o", "uu", "u")
+
+// normalizeTitle lowercases a title, removes diacritics of latin letters, punctuation and spaces and folds long vowels.
+// Marks of other scripts are kept, e.g. the dakuten of Japanese kana changes the word.
+func normalizeTitle(title string) string {
+	var b strings.Builder
+	latin := false
+	for _, r := range norm.NFKD.String(strings.ReplaceAll(title, "&", "and")) {
+		switch {
+		case unicode.Is(unicode.Mn, r):
+			// Diacritic split off by NFKD, e.g. the macron of ō
+			if !latin {
+				b.WriteRune(r)
+			}
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			latin = unicode.Is(unicode.Latin, r)
+			b.WriteRune(unicode.ToLower(r))
+		}
+	}
+
+	return romanisation.Replace(norm.NFC.String(b.String()))
+}
+
+// anidbMetadata holds the AniDB data not included in animetitles.xml
+type anidbMetadata struct {
+	StartDate string `json:"startDate"`
+	Episodes  int    `json:"episodes"`
+}
+
+// loadMetadata reads the AniDB metadata file, a JSON object keyed by AniDB ID:
+//
+//	{"23": {"startDate": "1998-04-03", "episodes": 26}}
+//
+// AniDB only publishes titles as a dump, start dates and episode counts need its HTTP API
+// (registered clients, strict rate limits), so they are provided by this file instead.
+func loadMetadata(path string) (map[int]anidbMetadata, error) {
+	body, err := os.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read AniDB metadata file")
+	}
+
+	raw := make(map[string]anidbMetadata)
+	if err := json.Unmarshal(body, &raw); err != nil {
+		return nil, errors.Wrap(err, "failed to unmarshal AniDB metadata file")
+	}
+
+	metadata := make(map[int]anidbMetadata, len(raw))
+	for key, value := range raw {
+		aid, err := strconv.Atoi(key)
+		if err != nil {
+			continue // Skip non-numeric AniDB IDs
+		}
+		metadata[aid] = value
+	}
+
+	return metadata, nil
+}
@@ -5,27 +5,83 @@ import "context"
 // CacheRepo defines the interface for cache database operations
 type CacheRepo interface {
 	// MAL cache operations
-	UpsertMAL(ctx context.Context, malID int, url, releaseDate, animeType string) error
+	UpsertMAL(ctx context.Context, malID int, url, releaseDate, animeType, status string, episodes int, japaneseTitle string, synonyms []string) error
+	GetMALEntries(ctx context.Context) ([]*MALCacheEntry, error)
+	TombstoneMAL(ctx context.Context, malID int) error
 	
 	// AniDB cache operations
 	GetAniDBIDs(ctx context.Context) (map[int]int, error)
-	UpsertAniDB(ctx context.Context, malID, anidbID int) error
-	
+	UpsertAniDB(ctx context.Context, malID, anidbID int, provenance Provenance) error
+	GetScrapeAttempts(ctx context.Context) (map[int]*ScrapeAttempt, error)
+	RecordScrapeAttempt(ctx context.Context, malID int, found bool) error
+
 	// TMDB cache operations
 	GetTMDBIDs(ctx context.Context) (map[int]int, error)
-	UpsertTMDB(ctx context.Context, malID, tmdbID int) error
+	UpsertTMDB(ctx context.Context, malID, tmdbID int, provenance Provenance) error
 	
 	// Query operations
 	GetEntriesByReleaseYear(ctx context.Context, year int) ([]*MALCacheEntry, error)
 	DeleteMAL(ctx context.Context, malID int) error
+
+	// History operations
+	GetMappingHistory(ctx context.Context, malID int) ([]*MappingHistoryEntry, error)
 }
 
 // MALCacheEntry represents a MAL cache entry
 type MALCacheEntry struct {
-	MalID       int
-	URL         string
-	ReleaseDate string
-	Type        string
-	CachedAt    string
-	LastUsed    string
+	MalID        int
+	URL          string
+	ReleaseDate  string
+	Type         string
+	Status       string
+	Episodes     int // 0 if unknown
+	CachedAt     string
+	LastUsed     string
+	TombstonedAt string // Set when the MAL ID is no longer returned by the ranking API
+	// Alternative titles, used by the dedupe title rule
+	JapaneseTitle string
+	Synonyms      []string
+}
+
+// ScrapeAttempt tracks AniDB scrape attempts for a MAL ID
+type ScrapeAttempt struct {
+	MalID         int
+	Misses        int // Consecutive attempts that found no AniDB ID
+	LastAttemptAt string
+}
+
+// MappingSource identifies which external ID a history entry refers to
+type MappingSource string
+
+const (
+	MappingSourceAniDB MappingSource = "anidb"
+	MappingSourceTMDB  MappingSource = "tmdb"
+)
+
+// Provenance describes where a cached ID came from
+type Provenance string
+
+const (
+	// ProvenanceMALScrape - AniDB ID scraped from the MAL anime page
+	ProvenanceMALScrape Provenance = "mal_scrape"
+	// ProvenanceAnimeList - ID taken from Anime-Lists anime-list.xml
+	ProvenanceAnimeList Provenance = "anime_list"
+	// ProvenanceTMDBSearch - TMDB ID matched through the TMDB search API
+	ProvenanceTMDBSearch Provenance = "tmdb_search"
+	// ProvenanceMappingFile - AniDB ID taken from a local offline mapping file
+	ProvenanceMappingFile Provenance = "mapping_file"
+	// ProvenanceAnimeListTitle - AniDB ID found by a title lookup in anime-list.xml
+	ProvenanceAnimeListTitle Provenance = "anime_list_title"
+	// ProvenanceCacheMigration - ID imported by the migrate-cache command
+	ProvenanceCacheMigration Provenance = "cache_migration"
+)
+
+// MappingHistoryEntry represents a single ID change recorded in mapping_history
+type MappingHistoryEntry struct {
+	MalID      int
+	Source     MappingSource
+	OldID      int
+	NewID      int
+	ChangedAt  string
+	Provenance Provenance
 }
@@ -1,10 +1,12 @@
 package domain
 
+import "time"
+
 // FetchMode defines the fetching behavior for IDs
 type FetchMode string
 
 const (
-	// FetchModeDefault - Default behavior (AniDB: only scrape for MAL IDs without AniDB ID, released in past 1 year, type = "tv"; TMDB: only fetch for movies without TMDB ID)
+	// FetchModeDefault - Default behavior (AniDB: only scrape MAL IDs without AniDB ID that are due according to the scrape policy; TMDB: only fetch for movies without TMDB ID)
 	FetchModeDefault FetchMode = "default"
 	// FetchModeMissing - Fetch all entries without ID (no filters)
 	FetchModeMissing FetchMode = "missing"
@@ -14,10 +16,71 @@ const (
 	FetchModeSkip FetchMode = "skip"
 )
 
+// ScrapeBreakageAction defines what happens when the MAL scraper looks broken
+type ScrapeBreakageAction string
+
+const (
+	// ScrapeBreakageWarn - Send a warning notification and continue the run
+	ScrapeBreakageWarn ScrapeBreakageAction = "warn"
+	// ScrapeBreakageFail - Fail the run
+	ScrapeBreakageFail ScrapeBreakageAction = "fail"
+)
+
+// AnimeStore defines where the anime lists passed between stages are stored
+type AnimeStore string
+
+const (
+	// AnimeStoreFile - One JSON file per stage output in the root path
+	AnimeStoreFile AnimeStore = "file"
+	// AnimeStoreSQLite - Tables in shinkrodb.db keyed by stage, JSON files are optional exports
+	AnimeStoreSQLite AnimeStore = "sqlite"
+)
+
 type Config struct {
 	MalClientID      string    `toml:"mal_client_id" mapstructure:"mal_client_id"`
 	TmdbApiKey       string    `toml:"tmdb_api_key" mapstructure:"tmdb_api_key"`
 	AniDBMode        FetchMode `toml:"anidb_mode" mapstructure:"anidb_mode"`
 	TMDBMode         FetchMode `toml:"tmdb_mode" mapstructure:"tmdb_mode"`
 	DiscordWebhookURL string   `toml:"discord_webhook_url" mapstructure:"discord_webhook_url"`
+	// TombstoneGraceDays is how long a MAL ID missing from the ranking is kept before it is purged from the cache
+	TombstoneGraceDays int `toml:"tombstone_grace_days" mapstructure:"tombstone_grace_days"`
+	// MaxScrapes caps the number of MAL pages scraped for AniDB IDs per run in default mode (0 = unlimited)
+	MaxScrapes int `toml:"max_scrapes" mapstructure:"max_scrapes"`
+	// AniDBResolvers lists the AniDB ID resolvers to try, in order
+	AniDBResolvers []string `toml:"anidb_resolvers" mapstructure:"anidb_resolvers"`
+	// AniDBMappingFile is the JSON mapping file (keyed by MAL ID) used by the mapping_file resolver
+	AniDBMappingFile string `toml:"anidb_mapping_file" mapstructure:"anidb_mapping_file"`
+	// ScrapeMinHitRatio is the minimum share of scraped MAL pages that must contain an external links block
+	ScrapeMinHitRatio float64 `toml:"scrape_min_hit_ratio" mapstructure:"scrape_min_hit_ratio"`
+	// ScrapeBreakageAction is applied when the hit ratio drops below ScrapeMinHitRatio
+	ScrapeBreakageAction ScrapeBreakageAction `toml:"scrape_breakage_action" mapstructure:"scrape_breakage_action"`
+	// MAL scraper tuning
+	ScrapeParallelism int           `toml:"scrape_parallelism" mapstructure:"scrape_parallelism"`
+	ScrapeDelay       time.Duration `toml:"scrape_delay" mapstructure:"scrape_delay"`
+	ScrapeRandomDelay time.Duration `toml:"scrape_random_delay" mapstructure:"scrape_random_delay"`
+	ScrapeTimeout     time.Duration `toml:"scrape_timeout" mapstructure:"scrape_timeout"`
+	ScrapeRetries     int           `toml:"scrape_retries" mapstructure:"scrape_retries"`
+	// TMDB API client tuning
+	TMDBConcurrency int           `toml:"tmdb_concurrency" mapstructure:"tmdb_concurrency"`
+	TMDBRateLimit   float64       `toml:"tmdb_rate_limit" mapstructure:"tmdb_rate_limit"` // requests per second
+	TMDBTimeout     time.Duration `toml:"tmdb_timeout" mapstructure:"tmdb_timeout"`
+	// OutputFormats lists the additional formats each anime list output file is exported to.
+	// The config file keys it by the file name without its .json extension (e.g. "for-shinkro").
+	OutputFormats map[AnimeFile][]OutputFormat `toml:"output_formats" mapstructure:"output_formats"`
+	// AnimeStore selects the storage of the anime lists passed between stages
+	AnimeStore AnimeStore `toml:"anime_store" mapstructure:"anime_store"`
+	// JSONExports lists the intermediate outputs also written as JSON with the sqlite store.
+	// The shinkro output is always written since it is the published file.
+	JSONExports []AnimeFile `toml:"json_exports" mapstructure:"json_exports"`
+	// DedupeRules lists the rules resolving AniDB duplicates, in order
+	DedupeRules []string `toml:"dedupe_rules" mapstructure:"dedupe_rules"`
+	// DedupeOverrideFile is the YAML file listing the MAL IDs to keep per AniDB ID, used by the override rule
+	DedupeOverrideFile string `toml:"dedupe_override_file" mapstructure:"dedupe_override_file"`
+	// AniDBMetadataFile is the JSON file with AniDB start dates and episode counts (keyed by AniDB ID),
+	// used by the start_date and episodes rules
+	AniDBMetadataFile string `toml:"anidb_metadata_file" mapstructure:"anidb_metadata_file"`
+	// CollisionPolicy decides what happens to entries sharing a TMDB ID or a TVDB ID and season
+	CollisionPolicy CollisionPolicy `toml:"collision_policy" mapstructure:"collision_policy"`
+	// Offline only uses the cached copies of anime-list.xml and animetitles.xml, set by the --offline run flag
+	Offline bool `toml:"offline" mapstructure:"offline"`
 }
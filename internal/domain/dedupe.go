@@ -1,4 +1,124 @@
 package domain
 
-// This file is kept for potential future domain types related to deduplication
-// All deduplication functionality has been moved to internal/dedupe/service.go
+// Dedupe rule names used in the dedupe_rules config option
+const (
+	DedupeRuleOverride  = "override"
+	DedupeRuleTitle     = "title"
+	DedupeRuleStartDate = "start_date"
+	DedupeRuleEpisodes  = "episodes"
+)
+
+// CollisionPolicy defines what happens to entries sharing a TMDB ID or a TVDB ID and season
+type CollisionPolicy string
+
+const (
+	// CollisionKeepAll - Report colliding entries but keep them all
+	CollisionKeepAll CollisionPolicy = "keep-all"
+	// CollisionKeepBest - Keep the best entry of each collision
+	CollisionKeepBest CollisionPolicy = "keep-best"
+	// CollisionDropAll - Remove every entry of a collision
+	CollisionDropAll CollisionPolicy = "drop-all"
+)
+
+// DedupeKey identifies what the entries of a duplicate group share
+type DedupeKey string
+
+const (
+	// DedupeKeyAniDB - tv entries sharing an AniDB ID
+	DedupeKeyAniDB DedupeKey = "anidb"
+	// DedupeKeyTMDB - Entries sharing a TMDB ID
+	DedupeKeyTMDB DedupeKey = "tmdb"
+	// DedupeKeyTVDB - Entries sharing a TVDB ID and season
+	DedupeKeyTVDB DedupeKey = "tvdb"
+)
+
+// DedupeRule picks the entries to keep from a group of MAL entries sharing an AniDB ID
+type DedupeRule interface {
+	// Name returns the rule name as used in the dedupe_rules config option
+	Name() string
+	// Resolve returns the MAL IDs of the candidates to keep and the reason.
+	// It returns no MAL IDs if the rule cannot tell the candidates apart.
+	Resolve(anidb *AniDBAnime, candidates []Anime) ([]int, string)
+}
+
+// AniDBAnime holds what is known about an AniDB entry when resolving duplicates.
+// StartDate and Episodes are only set if an AniDB metadata file is configured.
+type AniDBAnime struct {
+	ID        int
+	Titles    []AniDBTitle
+	StartDate string
+	Episodes  int
+}
+
+// AniDBTitle is a title of an AniDB entry from animetitles.xml
+type AniDBTitle struct {
+	Text string
+	// Type is main, official, syn or short
+	Type string
+	// Lang is the language, e.g. x-jat (romanised Japanese), en or ja
+	Lang string
+}
+
+// MainTitle returns the main title of the AniDB entry, "" if unknown
+func (a *AniDBAnime) MainTitle() string {
+	for _, title := range a.Titles {
+		if title.Type == "main" {
+			return title.Text
+		}
+	}
+
+	return ""
+}
+
+// DedupeDecision describes how a duplicate group was resolved
+type DedupeDecision string
+
+const (
+	// DedupeKeepAll - All entries are kept, no rule could tell them apart or the collision policy keeps them
+	DedupeKeepAll DedupeDecision = "keep-all"
+	// DedupeResolved - The rules picked the entries to keep, the others were removed
+	DedupeResolved DedupeDecision = "resolved"
+	// DedupeDropAll - All entries were removed
+	DedupeDropAll DedupeDecision = "drop-all"
+)
+
+// DedupeReport lists the duplicate groups of a dedupe run and how each was resolved
+type DedupeReport struct {
+	Groups []DedupeGroup `json:"groups" yaml:"groups"`
+}
+
+// Removed returns the number of entries removed over all groups
+func (r *DedupeReport) Removed() int {
+	removed := 0
+	for _, group := range r.Groups {
+		for _, entry := range group.Entries {
+			if !entry.Kept {
+				removed++
+			}
+		}
+	}
+
+	return removed
+}
+
+// DedupeGroup is a group of MAL entries sharing an AniDB ID, a TMDB ID or a TVDB ID and season
+type DedupeGroup struct {
+	Key        DedupeKey      `json:"key" yaml:"key"`
+	AnidbID    int            `json:"anidbid,omitempty" yaml:"anidbid,omitempty"`
+	AnidbTitle string         `json:"anidbTitle,omitempty" yaml:"anidbTitle,omitempty"`
+	TmdbID     int            `json:"tmdbid,omitempty" yaml:"tmdbid,omitempty"`
+	TvdbID     int            `json:"tvdbid,omitempty" yaml:"tvdbid,omitempty"`
+	Season     *int           `json:"season,omitempty" yaml:"season,omitempty"`
+	Entries    []DedupeEntry  `json:"entries" yaml:"entries"`
+	Decision   DedupeDecision `json:"decision" yaml:"decision"`
+	// Rules lists the rules that narrowed the group, in order
+	Rules  []string `json:"rules,omitempty" yaml:"rules,omitempty"`
+	Reason string   `json:"reason" yaml:"reason"`
+}
+
+// DedupeEntry is a MAL entry of a duplicate group
+type DedupeEntry struct {
+	MalID int    `json:"malid" yaml:"malid"`
+	Title string `json:"title" yaml:"title"`
+	Kept  bool   `json:"kept" yaml:"kept"`
+}
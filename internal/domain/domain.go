@@ -4,8 +4,10 @@ package domain
 type Anime struct {
 	MainTitle     string   `json:"title"`
 	EnglishTitle  string   `json:"enTitle,omitempty"`
-	JapaneseTitle string   `json:"-"` // Not serialized to JSON, kept in memory only
-	Synonyms      []string `json:"-"` // Not serialized to JSON, kept in memory only
+	JapaneseTitle string   `json:"-"` // Not serialized to JSON, persisted in mal_cache instead
+	Synonyms      []string `json:"-"` // Not serialized to JSON, persisted in mal_cache instead
+	Status        string   `json:"-"` // MAL airing status, persisted in mal_cache instead
+	Episodes      int      `json:"-"` // MAL episode count (0 if unknown), persisted in mal_cache instead
 	MalID         int      `json:"malid"`
 	AnidbID       int      `json:"anidbid,omitempty"`
 	TvdbID        int      `json:"tvdbid,omitempty"`
@@ -0,0 +1,34 @@
+package domain
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// OutputFormat is a format an anime list output is exported to next to its JSON file
+type OutputFormat string
+
+const (
+	// OutputFormatNDJSON - One anime per line, sorted by MAL ID
+	OutputFormatNDJSON OutputFormat = "ndjson"
+	// OutputFormatCSV - One anime per row with a header row, sorted by MAL ID
+	OutputFormatCSV OutputFormat = "csv"
+	// OutputFormatSQLite - Standalone read-only SQLite database with a single anime table
+	OutputFormatSQLite OutputFormat = "sqlite"
+)
+
+// OutputFormats lists the supported export formats
+var OutputFormats = []OutputFormat{OutputFormatNDJSON, OutputFormatCSV, OutputFormatSQLite}
+
+// Extension returns the file extension of exports in the format
+func (f OutputFormat) Extension() string {
+	if f == OutputFormatSQLite {
+		return ".db"
+	}
+	return "." + string(f)
+}
+
+// ExportPath returns the path of the export of the JSON file at path in format f
+func ExportPath(path string, f OutputFormat) string {
+	return strings.TrimSuffix(path, filepath.Ext(path)) + f.Extension()
+}
@@ -0,0 +1,19 @@
+package domain
+
+// IndexEntry is a MAL entry mapped to an external ID
+type IndexEntry struct {
+	MalID int `json:"malid"`
+	// Season is the TVDB season of the entry, nil when it is not known
+	Season *int `json:"season,omitempty"`
+}
+
+// AnimeIndex maps an external ID to the MAL entries using it, ordered by MAL ID
+type AnimeIndex map[int][]IndexEntry
+
+// Add maps id to a MAL entry, ignoring unset IDs
+func (idx AnimeIndex) Add(id int, entry IndexEntry) {
+	if id <= 0 {
+		return
+	}
+	idx[id] = append(idx[id], entry)
+}
@@ -0,0 +1,71 @@
+package domain
+
+// IDSource identifies the kind of ID a lookup starts from
+type IDSource string
+
+const (
+	// IDSourceMAL - MyAnimeList anime ID
+	IDSourceMAL IDSource = "mal"
+	// IDSourceAniDB - AniDB anime ID
+	IDSourceAniDB IDSource = "anidb"
+	// IDSourceTVDB - TVDB series ID
+	IDSourceTVDB IDSource = "tvdb"
+	// IDSourceTMDB - TMDB movie ID
+	IDSourceTMDB IDSource = "tmdb"
+)
+
+// LookupResult holds everything known about a single MAL entry
+type LookupResult struct {
+	MalID int
+	// Anime is the entry from the most complete output file that contains it
+	Anime *Anime
+	// Files lists the output files that contain the entry
+	Files []AnimeFile
+	// Deduped is set when the entry is in the TMDB output but was removed from the shinkro output
+	Deduped bool
+
+	// Cache state
+	Cache         *MALCacheEntry
+	CachedAniDBID int
+	CachedTMDBID  int
+	ScrapeAttempt *ScrapeAttempt
+	History       []*MappingHistoryEntry
+
+	// AnimeList is the anime-list.xml entry of the AniDB ID
+	AnimeList *AnimeListEntry
+
+	// Master file overrides
+	TVDBMaster *TVDBAnime
+	TMDBMaster *AnimeMovie
+
+	// Refreshed is set when the entry was re-resolved live
+	Refreshed *RefreshResult
+}
+
+// AnimeListEntry is a single entry of anime-list.xml
+type AnimeListEntry struct {
+	AnidbID           int
+	TvdbID            int
+	TmdbID            int
+	DefaultTvdbSeason string
+	Name              string
+}
+
+// RefreshResult holds the IDs found by re-resolving a single entry live
+type RefreshResult struct {
+	AnidbID         int
+	AnidbProvenance Provenance
+	TvdbID          int
+	TmdbID          int
+	TmdbProvenance  Provenance
+}
+
+// LatestProvenance returns the provenance of the most recent history entry of source, or "" if there is none
+func (r *LookupResult) LatestProvenance(source MappingSource) Provenance {
+	for i := len(r.History) - 1; i >= 0; i-- {
+		if r.History[i].Source == source {
+			return r.History[i].Provenance
+		}
+	}
+	return ""
+}
@@ -1,4 +1,33 @@
 package domain
 
-// This file is kept for potential future domain types related to MAL
-// All MAL functionality has been moved to internal/mal/service.go
+import "context"
+
+// AniDB resolver names used in the anidb_resolvers config option
+const (
+	AniDBResolverScraper     = "scraper"
+	AniDBResolverMappingFile = "mapping_file"
+	AniDBResolverAnimeList   = "anime_list"
+)
+
+// AniDBResolver resolves AniDB IDs for MAL entries
+type AniDBResolver interface {
+	// Name returns the resolver name as used in the anidb_resolvers config option
+	Name() string
+	// Provenance is recorded in the cache for AniDB IDs found by this resolver
+	Provenance() Provenance
+	// Resolve looks up AniDB IDs for the given entries and calls found for every match.
+	// It returns the MAL IDs that could not be checked at all, e.g. because of request failures.
+	Resolve(ctx context.Context, anime []Anime, found func(malID, anidbID int)) ([]int, error)
+}
+
+// TombstoneReport summarises MAL IDs that disappeared from the ranking during a run
+type TombstoneReport struct {
+	// Newly tombstoned MAL IDs (missing from the ranking for the first time)
+	Tombstoned []int
+	// MAL IDs still tombstoned but within the grace period
+	Pending []int
+	// MAL IDs that were tombstoned and are now returned by the ranking again
+	Restored []int
+	// MAL IDs removed from the cache after the grace period expired
+	Purged []int
+}
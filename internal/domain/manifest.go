@@ -0,0 +1,39 @@
+package domain
+
+// OutputSchemaVersion - version of the output file format, bumped on incompatible changes
+const OutputSchemaVersion = 1
+
+// BuildInfo describes the shinkrodb build generating the output
+type BuildInfo struct {
+	Version string `json:"version"`
+	Commit  string `json:"commit,omitempty"`
+	Date    string `json:"buildDate,omitempty"`
+}
+
+// Manifest describes a set of generated output files, so clients can check compatibility and staleness
+type Manifest struct {
+	SchemaVersion int             `json:"schemaVersion"`
+	GeneratedAt   string          `json:"generatedAt"`
+	Shinkrodb     BuildInfo       `json:"shinkrodb"`
+	Sources       ManifestSources `json:"sources"`
+	Files         []ManifestFile  `json:"files"`
+}
+
+// ManifestSources identifies the upstream data the output was built from
+type ManifestSources struct {
+	// AnimeListSHA256 is the SHA-256 of the anime-list.xml used, empty if it could not be loaded
+	AnimeListSHA256 string `json:"animeListSha256,omitempty"`
+}
+
+// ManifestFile describes a single output file
+type ManifestFile struct {
+	Name    string `json:"name"`
+	Records int    `json:"records"`
+	Size    int64  `json:"size"`
+	SHA256  string `json:"sha256"`
+	// Compression is set for compressed variants, Records then counts the decompressed content
+	Compression Compression `json:"compression,omitempty"`
+	// BaseSHA256 is set for deltas: the SHA-256 of the release the delta applies to.
+	// Records then counts the patch operations.
+	BaseSHA256 string `json:"baseSha256,omitempty"`
+}
@@ -9,6 +9,9 @@ type NotificationService interface {
 	
 	// SendError sends an error notification with error details
 	SendError(ctx context.Context, err error) error
+
+	// SendWarning sends a warning notification for problems that did not fail the run
+	SendWarning(ctx context.Context, title, message string) error
 }
 
 // Statistics holds the final statistics for the run
@@ -23,5 +26,10 @@ type Statistics struct {
 	TMDBCoveragePercent   float64
 	TVDBCoveragePercent   float64
 	DupeCount            int
+	// MAL IDs missing from the ranking (see TombstoneReport)
+	TombstonedMALIDs int
+	PendingMALIDs    int
+	RestoredMALIDs   int
+	PurgedMALIDs     int
 }
 
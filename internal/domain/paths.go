@@ -12,6 +12,40 @@ const (
 	ShinkroFile  AnimeFile = "for-shinkro.json"
 )
 
+// AnimeFiles lists the anime list outputs in pipeline order
+var AnimeFiles = []AnimeFile{MalIDFile, AniDBFile, TVDBFile, TMDBFile, ShinkroFile}
+
+// animeFileStages maps each anime list output to the stage writing it
+var animeFileStages = map[AnimeFile]Stage{
+	MalIDFile:   StageMAL,
+	AniDBFile:   StageAniDB,
+	TVDBFile:    StageTVDB,
+	TMDBFile:    StageTMDB,
+	ShinkroFile: StageDedupe,
+}
+
+// Stage returns the stage writing the anime list output, false if f is not one
+func (f AnimeFile) Stage() (Stage, bool) {
+	stage, ok := animeFileStages[f]
+	return stage, ok
+}
+
+// Reverse lookup indexes generated from the shinkro output
+const (
+	AniDBIndexFile = "anidb-index.json"
+	TVDBIndexFile  = "tvdb-index.json"
+	TMDBIndexFile  = "tmdb-index.json"
+)
+
+// Dedupe reports listing the duplicate groups of the last run, for curators to review
+const (
+	DupesJSONFile = "dupes.json"
+	DupesYAMLFile = "dupes.yaml"
+)
+
+// ManifestFileName - manifest describing the generated output files
+const ManifestFileName = "manifest.json"
+
 type AnimePath string
 
 // Paths holds all the file paths for anime data
@@ -22,18 +56,32 @@ type Paths struct {
 	TVDBPath    AnimePath
 	TMDBPath    AnimePath
 	ShinkroPath AnimePath
+	// Index files map external IDs to MAL IDs, they are not anime lists
+	AniDBIndexPath string
+	TVDBIndexPath  string
+	TMDBIndexPath  string
+	ManifestPath   string
+	// Dedupe reports are not published outputs, the manifest does not describe them
+	DupesJSONPath string
+	DupesYAMLPath string
 }
 
 // NewPaths creates a new Paths instance with all paths initialized
 func NewPaths(rootDir string) *Paths {
 	rootDir = filepath.Join(rootDir, "shinkrodb")
 	return &Paths{
-		RootDir:     rootDir,
-		MalIDPath:   makeAnimePath(rootDir, MalIDFile),
-		AniDBPath:   makeAnimePath(rootDir, AniDBFile),
-		TVDBPath:    makeAnimePath(rootDir, TVDBFile),
-		TMDBPath:    makeAnimePath(rootDir, TMDBFile),
-		ShinkroPath: makeAnimePath(rootDir, ShinkroFile),
+		RootDir:        rootDir,
+		MalIDPath:      makeAnimePath(rootDir, MalIDFile),
+		AniDBPath:      makeAnimePath(rootDir, AniDBFile),
+		TVDBPath:       makeAnimePath(rootDir, TVDBFile),
+		TMDBPath:       makeAnimePath(rootDir, TMDBFile),
+		ShinkroPath:    makeAnimePath(rootDir, ShinkroFile),
+		AniDBIndexPath: filepath.Join(rootDir, AniDBIndexFile),
+		TVDBIndexPath:  filepath.Join(rootDir, TVDBIndexFile),
+		TMDBIndexPath:  filepath.Join(rootDir, TMDBIndexFile),
+		ManifestPath:   filepath.Join(rootDir, ManifestFileName),
+		DupesJSONPath:  filepath.Join(rootDir, DupesJSONFile),
+		DupesYAMLPath:  filepath.Join(rootDir, DupesYAMLFile),
 	}
 }
 
@@ -41,3 +89,17 @@ func makeAnimePath(rootDir string, af AnimeFile) AnimePath {
 	return AnimePath(filepath.Join(rootDir, string(af)))
 }
 
+
+// OutputFiles returns the paths of all generated output files described by the manifest
+func (p *Paths) OutputFiles() []string {
+	return []string{
+		string(p.MalIDPath),
+		string(p.AniDBPath),
+		string(p.TVDBPath),
+		string(p.TMDBPath),
+		string(p.ShinkroPath),
+		p.AniDBIndexPath,
+		p.TVDBIndexPath,
+		p.TMDBIndexPath,
+	}
+}
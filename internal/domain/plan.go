@@ -0,0 +1,47 @@
+package domain
+
+import (
+	"strconv"
+	"time"
+)
+
+// FetchPlan describes what a stage would fetch, computed without making any requests
+type FetchPlan struct {
+	Candidates int           // Entries considered for fetching
+	ToFetch    []Anime       // Entries that would be fetched, in fetch order
+	Requests   int           // Estimated number of requests
+	Duration   time.Duration // Estimated duration of the requests
+	// Output is what the stage would write if the fetches find nothing new, nil if unknown
+	Output []Anime
+	Note   string
+}
+
+// CountByType counts the entries to fetch by media type
+func (p *FetchPlan) CountByType() map[string]int {
+	counts := make(map[string]int)
+	for _, anime := range p.ToFetch {
+		animeType := anime.Type
+		if animeType == "" {
+			animeType = "unknown"
+		}
+		counts[animeType]++
+	}
+
+	return counts
+}
+
+// CountByYear counts the entries to fetch by release year
+func (p *FetchPlan) CountByYear() map[string]int {
+	counts := make(map[string]int)
+	for _, anime := range p.ToFetch {
+		year := "unknown"
+		if len(anime.ReleaseDate) >= 4 {
+			if _, err := strconv.Atoi(anime.ReleaseDate[:4]); err == nil {
+				year = anime.ReleaseDate[:4]
+			}
+		}
+		counts[year]++
+	}
+
+	return counts
+}
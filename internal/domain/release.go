@@ -0,0 +1,57 @@
+package domain
+
+import "strings"
+
+// Compression is a compressed variant a published output is written in
+type Compression string
+
+const (
+	// CompressionGzip - gzip compressed copy (<output>.gz)
+	CompressionGzip Compression = "gzip"
+	// CompressionZstd - zstd compressed copy (<output>.zst)
+	CompressionZstd Compression = "zstd"
+)
+
+// Compressions lists the compressed variants written for published outputs
+var Compressions = []Compression{CompressionGzip, CompressionZstd}
+
+// Extension returns the file extension appended to compressed variants
+func (c Compression) Extension() string {
+	if c == CompressionZstd {
+		return ".zst"
+	}
+	return ".gz"
+}
+
+// CompressedPath returns the path of the compressed variant c of the file at path
+func CompressedPath(path string, c Compression) string {
+	return path + c.Extension()
+}
+
+// DeltaPath returns the path of the delta of the JSON file at path against its previous release
+func DeltaPath(path string) string {
+	return strings.TrimSuffix(path, ".json") + ".delta.json"
+}
+
+// ReleaseDelta is a JSON-patch style delta between two releases of an anime list output.
+// Paths address entries by MAL ID ("/<malid>"), since the lists are keyed by MAL ID.
+type ReleaseDelta struct {
+	// BaseSHA256 is the SHA-256 of the previous release the patch applies to
+	BaseSHA256 string `json:"baseSha256"`
+	// SHA256 is the SHA-256 of the release the patch leads to
+	SHA256 string           `json:"sha256"`
+	Patch  []PatchOperation `json:"patch"`
+}
+
+// PatchOperation is a single add, remove or replace operation of a ReleaseDelta
+type PatchOperation struct {
+	Op    string `json:"op"`
+	Path  string `json:"path"`
+	Value *Anime `json:"value,omitempty"`
+}
+
+const (
+	PatchOpAdd     = "add"
+	PatchOpRemove  = "remove"
+	PatchOpReplace = "replace"
+)
@@ -2,12 +2,21 @@ package domain
 
 import (
 	"context"
+	"iter"
 )
 
 // AnimeRepository defines the interface for anime data storage
 type AnimeRepository interface {
 	Get(ctx context.Context, path AnimePath) ([]Anime, error)
 	Store(ctx context.Context, path AnimePath, anime []Anime) error
+	// Iter streams the anime stored at path one entry at a time.
+	// A read error is yielded once and ends the sequence.
+	Iter(ctx context.Context, path AnimePath) iter.Seq2[Anime, error]
+	// StoreIter stores the anime of seq as they are produced. seq may read from path itself,
+	// the file is only replaced once seq is exhausted.
+	StoreIter(ctx context.Context, path AnimePath, seq iter.Seq2[Anime, error]) error
+	// Exists reports whether anime were stored at path, e.g. by a previous run
+	Exists(ctx context.Context, path AnimePath) (bool, error)
 }
 
 // MappingRepository defines the interface for mapping data storage
@@ -44,3 +53,27 @@ type AnimeMapping struct {
 	SkipMalEpisodes  []int        `yaml:"skipMalEpisodes,omitempty"`
 }
 
+
+// IndexRepository defines the interface for reverse lookup index storage
+type IndexRepository interface {
+	StoreIndex(ctx context.Context, path string, index AnimeIndex) error
+}
+
+// ManifestRepository defines the interface for output manifest storage
+type ManifestRepository interface {
+	StoreManifest(ctx context.Context, path string, manifest *Manifest) error
+}
+
+// DedupeReportRepository defines the interface for dedupe report storage
+type DedupeReportRepository interface {
+	// StoreDedupeReport saves the report as YAML if path ends in .yaml, as JSON otherwise
+	StoreDedupeReport(ctx context.Context, path string, report *DedupeReport) error
+}
+
+// ReleaseRepository defines the interface for published release storage
+type ReleaseRepository interface {
+	// GetRelease returns the raw content of a published file, nil if it does not exist
+	GetRelease(ctx context.Context, path string) ([]byte, error)
+	StoreCompressed(ctx context.Context, path string, data []byte, compression Compression) error
+	StoreDelta(ctx context.Context, path string, delta *ReleaseDelta) error
+}
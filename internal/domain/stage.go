@@ -0,0 +1,26 @@
+package domain
+
+// Stage names a step of the run pipeline
+type Stage string
+
+const (
+	// StageMAL - Fetch MAL IDs from the MAL ranking API
+	StageMAL Stage = "mal"
+	// StageAniDB - Resolve AniDB IDs for MAL IDs
+	StageAniDB Stage = "anidb"
+	// StageTVDB - Map TVDB IDs from AniDB IDs and update the TVDB master
+	StageTVDB Stage = "tvdb"
+	// StageTMDB - Map TMDB IDs for movies and update the TMDB master
+	StageTMDB Stage = "tmdb"
+	// StageDedupe - Remove duplicates and write the shinkro output
+	StageDedupe Stage = "dedupe"
+)
+
+// StageSelection selects the pipeline stages of a run.
+// Only limits the run to the given stages (all stages if empty);
+// From and Until trim the selection to a range of the pipeline and may be empty.
+type StageSelection struct {
+	Only  []Stage
+	From  Stage
+	Until Stage
+}
@@ -11,8 +11,7 @@ import (
 	"github.com/varoOP/shinkrodb/internal/domain"
 )
 
-func FormatTMDB(rootPath string, mappingRepo domain.MappingRepository) error {
-	ctx := context.Background()
+func FormatTMDB(ctx context.Context, rootPath string, mappingRepo domain.MappingRepository) error {
 	tmdbPath := filepath.Join(rootPath, "tmdb-mal-master.yaml")
 	
 	tmdb, err := mappingRepo.GetTMDBMaster(ctx, tmdbPath)
@@ -30,8 +29,7 @@ func FormatTMDB(rootPath string, mappingRepo domain.MappingRepository) error {
 	return nil
 }
 
-func FormatTVDB(rootPath string, mappingRepo domain.MappingRepository) error {
-	ctx := context.Background()
+func FormatTVDB(ctx context.Context, rootPath string, mappingRepo domain.MappingRepository) error {
 	tvdbPath := filepath.Join(rootPath, "tvdb-mal-master.yaml")
 	
 	tvdb, err := mappingRepo.GetTVDBMaster(ctx, tvdbPath)
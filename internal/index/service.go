@@ -0,0 +1,154 @@
+package index
+
+import (
+	"context"
+	"iter"
+	"path/filepath"
+	"sort"
+	"strconv"
+
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog"
+	"github.com/varoOP/shinkrodb/internal/domain"
+	"github.com/varoOP/shinkrodb/pkg/animelist"
+)
+
+type Service interface {
+	// GenerateIndexes builds and stores the reverse lookup indexes, TVDB entries carry their season from seasons
+	GenerateIndexes(ctx context.Context, anime iter.Seq2[domain.Anime, error], seasons *Seasons) (*Indexes, error)
+	// Seasons loads the known TVDB seasons from the TVDB master file and anime-list.xml
+	Seasons(ctx context.Context, rootPath string) *Seasons
+}
+
+// Indexes holds the reverse lookup indexes of an anime list
+type Indexes struct {
+	AniDB domain.AnimeIndex
+	TVDB  domain.AnimeIndex
+	TMDB  domain.AnimeIndex
+}
+
+type service struct {
+	log            zerolog.Logger
+	config         *domain.Config
+	indexRepo      domain.IndexRepository
+	mappingRepo    domain.MappingRepository
+	anidbIndexPath string
+	tvdbIndexPath  string
+	tmdbIndexPath  string
+}
+
+// NewService creates an index service that stores the indexes at the given paths
+func NewService(log zerolog.Logger, config *domain.Config, indexRepo domain.IndexRepository, mappingRepo domain.MappingRepository, anidbIndexPath, tvdbIndexPath, tmdbIndexPath string) Service {
+	return &service{
+		log:            log.With().Str("module", "index").Logger(),
+		config:         config,
+		indexRepo:      indexRepo,
+		mappingRepo:    mappingRepo,
+		anidbIndexPath: anidbIndexPath,
+		tvdbIndexPath:  tvdbIndexPath,
+		tmdbIndexPath:  tmdbIndexPath,
+	}
+}
+
+// GenerateIndexes builds the AniDB, TVDB and TMDB to MAL indexes of anime while it is streamed and stores them.
+// TVDB entries carry the season from the TVDB master file, or from anime-list.xml when the
+// master file has no mapping for the entry.
+func (s *service) GenerateIndexes(ctx context.Context, anime iter.Seq2[domain.Anime, error], seasons *Seasons) (*Indexes, error) {
+	indexes := &Indexes{
+		AniDB: make(domain.AnimeIndex),
+		TVDB:  make(domain.AnimeIndex),
+		TMDB:  make(domain.AnimeIndex),
+	}
+
+	for v, err := range anime {
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to get anime list")
+		}
+		indexes.AniDB.Add(v.AnidbID, domain.IndexEntry{MalID: v.MalID})
+		indexes.TMDB.Add(v.TmdbID, domain.IndexEntry{MalID: v.MalID})
+		indexes.TVDB.Add(v.TvdbID, domain.IndexEntry{MalID: v.MalID, Season: seasons.Season(v)})
+	}
+
+	for _, index := range []domain.AnimeIndex{indexes.AniDB, indexes.TVDB, indexes.TMDB} {
+		for _, entries := range index {
+			sort.Slice(entries, func(i, j int) bool { return entries[i].MalID < entries[j].MalID })
+		}
+	}
+
+	if err := s.indexRepo.StoreIndex(ctx, s.anidbIndexPath, indexes.AniDB); err != nil {
+		return nil, errors.Wrap(err, "failed to store AniDB index")
+	}
+	if err := s.indexRepo.StoreIndex(ctx, s.tvdbIndexPath, indexes.TVDB); err != nil {
+		return nil, errors.Wrap(err, "failed to store TVDB index")
+	}
+	if err := s.indexRepo.StoreIndex(ctx, s.tmdbIndexPath, indexes.TMDB); err != nil {
+		return nil, errors.Wrap(err, "failed to store TMDB index")
+	}
+
+	s.log.Info().
+		Int("anidb_ids", len(indexes.AniDB)).
+		Int("tvdb_ids", len(indexes.TVDB)).
+		Int("tmdb_ids", len(indexes.TMDB)).
+		Msg("Reverse lookup indexes generated")
+
+	return indexes, nil
+}
+
+// Seasons holds the sources of known TVDB seasons
+type Seasons struct {
+	master    map[int]domain.TVDBAnime
+	animeList *animelist.AnimeList
+}
+
+// Seasons loads the TVDB master file and anime-list.xml, missing sources are skipped
+func (s *service) Seasons(ctx context.Context, rootPath string) *Seasons {
+	seasons := &Seasons{master: make(map[int]domain.TVDBAnime)}
+
+	master, err := s.mappingRepo.GetTVDBMaster(ctx, filepath.Join(rootPath, "tvdb-mal-master.yaml"))
+	if err != nil {
+		s.log.Warn().Err(err).Msg("failed to get TVDB master, index seasons will use anime-list.xml only")
+	} else {
+		for _, v := range master.Anime {
+			if v.Tvdbid != 0 {
+				seasons.master[v.Malid] = v
+			}
+		}
+	}
+
+	// Load anime-list.xml from current directory (./) instead of root-path
+	al, err := animelist.NewAnimeList(ctx, ".", animelist.WithOffline(s.config.Offline))
+	if err != nil {
+		s.log.Warn().Err(err).Msg("failed to load anime-list.xml, index seasons will use the TVDB master only")
+	} else {
+		seasons.animeList = al
+	}
+
+	return seasons
+}
+
+// Season returns the TVDB season of an entry, nil if it is not known.
+// A season only applies if its source maps the entry to the same TVDB ID.
+func (ss *Seasons) Season(anime domain.Anime) *int {
+	if v, ok := ss.master[anime.MalID]; ok && v.Tvdbid == anime.TvdbID {
+		season := v.TvdbSeason
+		return &season
+	}
+
+	if ss.animeList != nil && anime.AnidbID > 0 && ss.animeList.GetTvdbID(anime.AnidbID) == anime.TvdbID {
+		// Absolute numbering ("a") has no season
+		if season, err := strconv.Atoi(ss.animeList.GetDefaultTvdbSeason(anime.AnidbID)); err == nil {
+			return &season
+		}
+	}
+
+	return nil
+}
+
+// AnimeListSHA256 returns the SHA-256 of the anime-list.xml the seasons were loaded from, empty if it was not loaded
+func (ss *Seasons) AnimeListSHA256() string {
+	if ss.animeList == nil {
+		return ""
+	}
+
+	return ss.animeList.SHA256()
+}
@@ -0,0 +1,209 @@
+package mal
+
+import (
+	"sort"
+	"time"
+
+	"github.com/varoOP/shinkrodb/internal/domain"
+)
+
+const (
+	// maxScrapeInterval caps the backoff applied to entries that keep missing
+	maxScrapeInterval = 180 * 24 * time.Hour
+	// missPenalty lowers the priority of an entry for every consecutive miss
+	missPenalty = 5
+)
+
+// scrapeTier is the base priority and minimum time between scrapes of an entry
+type scrapeTier struct {
+	priority int
+	interval time.Duration
+}
+
+// scrapePolicy decides which MAL entries are due for an AniDB scrape and in which order.
+// Priority and frequency depend on airing status, start date, media type and previous misses.
+type scrapePolicy struct {
+	mode domain.FetchMode
+	// budget caps the entries scraped per run in default mode, 0 = unlimited
+	budget   int
+	now      time.Time
+	statuses map[int]string
+	attempts map[int]*domain.ScrapeAttempt
+}
+
+func newScrapePolicy(config *domain.Config, now time.Time, statuses map[int]string, attempts map[int]*domain.ScrapeAttempt) *scrapePolicy {
+	if statuses == nil {
+		statuses = make(map[int]string)
+	}
+	if attempts == nil {
+		attempts = make(map[int]*domain.ScrapeAttempt)
+	}
+
+	return &scrapePolicy{
+		mode:     config.AniDBMode,
+		budget:   config.MaxScrapes,
+		now:      now,
+		statuses: statuses,
+		attempts: attempts,
+	}
+}
+
+// tier returns the base scrape tier for an entry from its airing status and start date
+func (p *scrapePolicy) tier(anime domain.Anime) scrapeTier {
+	switch p.statuses[anime.MalID] {
+	case "currently_airing", "not_yet_aired":
+		return scrapeTier{priority: 100, interval: 24 * time.Hour}
+	}
+
+	start, ok := parseStartDate(anime.ReleaseDate)
+	if !ok {
+		return scrapeTier{priority: 10, interval: 90 * 24 * time.Hour}
+	}
+
+	age := p.now.Sub(start)
+	switch {
+	case age < 0:
+		// Not started yet, MAL usually adds the AniDB link shortly before airing
+		return scrapeTier{priority: 90, interval: 24 * time.Hour}
+	case age <= 365*24*time.Hour:
+		return scrapeTier{priority: 80, interval: 3 * 24 * time.Hour}
+	case age <= 5*365*24*time.Hour:
+		return scrapeTier{priority: 40, interval: 30 * 24 * time.Hour}
+	default:
+		return scrapeTier{priority: 20, interval: 90 * 24 * time.Hour}
+	}
+}
+
+// typeWeight favours media types that are most likely to have an AniDB entry
+func typeWeight(animeType string) int {
+	switch animeType {
+	case "tv":
+		return 20
+	case "movie", "ona", "ova", "special", "tv_special":
+		return 10
+	default:
+		return 0
+	}
+}
+
+// priority returns the scrape priority of an entry, higher is scraped first
+func (p *scrapePolicy) priority(anime domain.Anime) int {
+	priority := p.tier(anime).priority + typeWeight(anime.Type)
+	if attempt, ok := p.attempts[anime.MalID]; ok {
+		priority -= attempt.Misses * missPenalty
+	}
+
+	if priority < 1 {
+		priority = 1
+	}
+
+	return priority
+}
+
+// interval returns the minimum time between scrapes of an entry.
+// The base interval doubles with every consecutive miss, up to maxScrapeInterval.
+func (p *scrapePolicy) interval(anime domain.Anime) time.Duration {
+	interval := p.tier(anime).interval
+	if attempt, ok := p.attempts[anime.MalID]; ok {
+		for i := 0; i < attempt.Misses && interval < maxScrapeInterval; i++ {
+			interval *= 2
+		}
+	}
+
+	if interval > maxScrapeInterval {
+		interval = maxScrapeInterval
+	}
+
+	return interval
+}
+
+// isDue reports whether an entry should be scraped in this run
+func (p *scrapePolicy) isDue(anime domain.Anime) bool {
+	lastAttempt, ok := p.lastAttempt(anime.MalID)
+	if !ok {
+		return true
+	}
+
+	return p.now.Sub(lastAttempt) >= p.interval(anime)
+}
+
+// lastAttempt returns when an entry was last scraped
+func (p *scrapePolicy) lastAttempt(malID int) (time.Time, bool) {
+	attempt, ok := p.attempts[malID]
+	if !ok {
+		return time.Time{}, false
+	}
+
+	t, err := time.Parse(time.RFC3339, attempt.LastAttemptAt)
+	if err != nil {
+		return time.Time{}, false
+	}
+
+	return t, true
+}
+
+// attemptedBefore reports whether entry a was attempted less recently than entry b.
+// Entries that were never attempted come first. The second return value is false on a tie.
+func (p *scrapePolicy) attemptedBefore(a, b int) (bool, bool) {
+	ta, okA := p.lastAttempt(a)
+	tb, okB := p.lastAttempt(b)
+	if okA != okB {
+		return !okA, true
+	}
+	if !okA || ta.Equal(tb) {
+		return false, false
+	}
+
+	return ta.Before(tb), true
+}
+
+// prioritize orders candidates and, in default mode, applies the per-run scrape budget.
+// In default mode the highest priority entries go first; in missing and all modes
+// the least recently attempted entries go first and every candidate is scraped,
+// since those modes are an explicit request to fetch them all.
+func (p *scrapePolicy) prioritize(candidates []domain.Anime) []domain.Anime {
+	priorities := make(map[int]int, len(candidates))
+	for _, anime := range candidates {
+		priorities[anime.MalID] = p.priority(anime)
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		a, b := candidates[i].MalID, candidates[j].MalID
+
+		if p.mode == domain.FetchModeDefault {
+			if priorities[a] != priorities[b] {
+				return priorities[a] > priorities[b]
+			}
+			if before, ok := p.attemptedBefore(a, b); ok {
+				return before
+			}
+		} else {
+			if before, ok := p.attemptedBefore(a, b); ok {
+				return before
+			}
+			if priorities[a] != priorities[b] {
+				return priorities[a] > priorities[b]
+			}
+		}
+
+		// Newer MAL IDs first
+		return a > b
+	})
+
+	if p.mode == domain.FetchModeDefault && p.budget > 0 && len(candidates) > p.budget {
+		return candidates[:p.budget]
+	}
+
+	return candidates
+}
+
+// parseStartDate parses a MAL start date ("YYYY-MM-DD", "YYYY-MM" or "YYYY")
+func parseStartDate(date string) (time.Time, bool) {
+	for _, layout := range []string{"2006-01-02", "2006-01", "2006"} {
+		if t, err := time.Parse(layout, date); err == nil {
+			return t, true
+		}
+	}
+
+	return time.Time{}, false
+}
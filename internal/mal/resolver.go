@@ -0,0 +1,25 @@
+package mal
+
+import (
+	"github.com/rs/zerolog"
+	"github.com/varoOP/shinkrodb/internal/domain"
+)
+
+// newResolvers builds the AniDB resolvers in the order configured by anidb_resolvers
+func newResolvers(log zerolog.Logger, config *domain.Config, notifier domain.NotificationService) []domain.AniDBResolver {
+	resolvers := make([]domain.AniDBResolver, 0, len(config.AniDBResolvers))
+	for _, name := range config.AniDBResolvers {
+		switch name {
+		case domain.AniDBResolverScraper:
+			resolvers = append(resolvers, newScraperResolver(log, config, notifier))
+		case domain.AniDBResolverMappingFile:
+			resolvers = append(resolvers, newMappingFileResolver(log, config.AniDBMappingFile))
+		case domain.AniDBResolverAnimeList:
+			resolvers = append(resolvers, newAnimeListResolver(log, ".", config.Offline))
+		default:
+			log.Warn().Str("resolver", name).Msg("unknown AniDB resolver, skipping")
+		}
+	}
+
+	return resolvers
+}
@@ -0,0 +1,65 @@
+package mal
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog"
+	"github.com/varoOP/shinkrodb/internal/domain"
+	"github.com/varoOP/shinkrodb/pkg/animelist"
+)
+
+// animeListResolver resolves AniDB IDs by looking up MAL titles in anime-list.xml.
+// Only unambiguous matches (a title matching exactly one AniDB entry) are accepted.
+type animeListResolver struct {
+	log      zerolog.Logger
+	cacheDir string
+	offline  bool
+}
+
+func newAnimeListResolver(log zerolog.Logger, cacheDir string, offline bool) *animeListResolver {
+	return &animeListResolver{
+		log:      log.With().Str("resolver", domain.AniDBResolverAnimeList).Logger(),
+		cacheDir: cacheDir,
+		offline:  offline,
+	}
+}
+
+func (r *animeListResolver) Name() string {
+	return domain.AniDBResolverAnimeList
+}
+
+func (r *animeListResolver) Provenance() domain.Provenance {
+	return domain.ProvenanceAnimeListTitle
+}
+
+func (r *animeListResolver) Resolve(ctx context.Context, anime []domain.Anime, found func(malID, anidbID int)) ([]int, error) {
+	al, err := animelist.NewAnimeList(ctx, r.cacheDir, animelist.WithOffline(r.offline))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to load anime list")
+	}
+
+	matched, ambiguous := 0, 0
+	for _, v := range anime {
+		for _, title := range []string{v.MainTitle, v.EnglishTitle} {
+			if title == "" {
+				continue
+			}
+
+			ids := al.GetAnidbIDsByName(title)
+			if len(ids) > 1 {
+				ambiguous++
+				r.log.Debug().Int("mal_id", v.MalID).Str("title", title).Ints("anidb_ids", ids).Msg("ambiguous title match, skipping")
+				break
+			}
+			if len(ids) == 1 {
+				found(v.MalID, ids[0])
+				matched++
+				break
+			}
+		}
+	}
+
+	r.log.Info().Int("matched", matched).Int("ambiguous", ambiguous).Msg("Resolved AniDB IDs from anime-list.xml titles")
+	return nil, nil
+}
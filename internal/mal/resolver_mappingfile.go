@@ -0,0 +1,93 @@
+package mal
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"strconv"
+
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog"
+	"github.com/varoOP/shinkrodb/internal/domain"
+)
+
+// mappingFileResolver resolves AniDB IDs from a local offline mapping file.
+// The file is a JSON object keyed by MAL ID, whose values are either the AniDB ID
+// or an object with an "anidb_id" field:
+//
+//	{"1": 23, "5": {"anidb_id": 28}}
+type mappingFileResolver struct {
+	log  zerolog.Logger
+	path string
+}
+
+func newMappingFileResolver(log zerolog.Logger, path string) *mappingFileResolver {
+	return &mappingFileResolver{
+		log:  log.With().Str("resolver", domain.AniDBResolverMappingFile).Logger(),
+		path: path,
+	}
+}
+
+func (r *mappingFileResolver) Name() string {
+	return domain.AniDBResolverMappingFile
+}
+
+func (r *mappingFileResolver) Provenance() domain.Provenance {
+	return domain.ProvenanceMappingFile
+}
+
+func (r *mappingFileResolver) Resolve(ctx context.Context, anime []domain.Anime, found func(malID, anidbID int)) ([]int, error) {
+	mapping, err := r.load()
+	if err != nil {
+		return nil, err
+	}
+
+	matched := 0
+	for _, v := range anime {
+		if anidbID, ok := mapping[v.MalID]; ok && anidbID > 0 {
+			found(v.MalID, anidbID)
+			matched++
+		}
+	}
+
+	r.log.Info().Str("path", r.path).Int("entries", len(mapping)).Int("matched", matched).Msg("Resolved AniDB IDs from mapping file")
+	return nil, nil
+}
+
+// load reads the mapping file into a map of MAL ID to AniDB ID
+func (r *mappingFileResolver) load() (map[int]int, error) {
+	body, err := os.ReadFile(r.path)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read AniDB mapping file")
+	}
+
+	raw := make(map[string]json.RawMessage)
+	if err := json.Unmarshal(body, &raw); err != nil {
+		return nil, errors.Wrap(err, "failed to unmarshal AniDB mapping file")
+	}
+
+	mapping := make(map[int]int, len(raw))
+	for key, value := range raw {
+		malID, err := strconv.Atoi(key)
+		if err != nil {
+			r.log.Debug().Str("key", key).Msg("skipping non-numeric MAL ID in mapping file")
+			continue
+		}
+
+		var anidbID int
+		if err := json.Unmarshal(value, &anidbID); err != nil {
+			entry := struct {
+				AnidbID int `json:"anidb_id"`
+			}{}
+			if err := json.Unmarshal(value, &entry); err != nil {
+				r.log.Debug().Int("mal_id", malID).Msg("skipping invalid entry in mapping file")
+				continue
+			}
+			anidbID = entry.AnidbID
+		}
+
+		mapping[malID] = anidbID
+	}
+
+	return mapping, nil
+}
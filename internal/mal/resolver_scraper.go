@@ -0,0 +1,433 @@
+package mal
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gocolly/colly"
+	"github.com/gocolly/colly/extensions"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog"
+	"github.com/varoOP/shinkrodb/internal/domain"
+)
+
+// minBreakageSample is the number of visited pages needed before the hit and error ratios are trusted
+const minBreakageSample = 10
+
+// ErrScraperBroken is returned when the scraper looks broken and scrape_breakage_action is fail
+var ErrScraperBroken = errors.New("MAL scraper looks broken")
+
+var (
+	anidbLinkRegex = regexp.MustCompile(`aid=(\d+)`)
+	malURLRegex    = regexp.MustCompile(`/anime/(\d+)`)
+)
+
+// scrapeStats tracks scraper health to detect MAL markup changes
+type scrapeStats struct {
+	mu sync.Mutex
+	// Pages whose body was parsed, error responses are left out so rate limiting
+	// and server errors don't look like changed markup
+	parsed int
+	// Pages containing an external links block
+	withExternalLinks map[string]bool
+	// Pages containing an AniDB link
+	withAniDB int
+	// Colly OnError events
+	errors int
+	// HTTP status codes of all responses, 0 for network errors
+	statuses map[int]int
+}
+
+func newScrapeStats() *scrapeStats {
+	return &scrapeStats{
+		withExternalLinks: make(map[string]bool),
+		statuses:          make(map[int]int),
+	}
+}
+
+func (st *scrapeStats) response(status int) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	st.parsed++
+	st.statuses[status]++
+}
+
+func (st *scrapeStats) error(status int) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	st.errors++
+	st.statuses[status]++
+}
+
+func (st *scrapeStats) externalLinks(url string) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	st.withExternalLinks[url] = true
+}
+
+func (st *scrapeStats) anidbLink() {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	st.withAniDB++
+}
+
+// ratios returns the share of parsed pages that contained an external links block,
+// the share of visited pages that failed and the number of visited (parsed or failed) pages
+func (st *scrapeStats) ratios() (float64, float64, int) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	visited := st.parsed + st.errors
+	if visited == 0 {
+		return 1, 0, 0
+	}
+
+	hitRatio := 1.0
+	if st.parsed > 0 {
+		hitRatio = float64(len(st.withExternalLinks)) / float64(st.parsed)
+	}
+	return hitRatio, float64(st.errors) / float64(visited), visited
+}
+
+func (st *scrapeStats) String() string {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	codes := make([]int, 0, len(st.statuses))
+	for code := range st.statuses {
+		codes = append(codes, code)
+	}
+	sort.Ints(codes)
+
+	statuses := make([]string, 0, len(codes))
+	for _, code := range codes {
+		statuses = append(statuses, fmt.Sprintf("%d: %d", code, st.statuses[code]))
+	}
+
+	return fmt.Sprintf("parsed %d pages, %d with external links, %d with AniDB links, %d errors (HTTP statuses %s)",
+		st.parsed, len(st.withExternalLinks), st.withAniDB, st.errors, strings.Join(statuses, ", "))
+}
+
+// scrapeFailure records a failed MAL page request for the retry queue
+type scrapeFailure struct {
+	status    int
+	err       error
+	retryable bool
+}
+
+// isMissingStatus reports whether a failed request proves the page does not exist:
+// a 404 or 410 for a removed entry. Such pages count as a miss instead of unchecked,
+// other client errors (e.g. 401/403) say nothing about the page and leave it unchecked.
+func isMissingStatus(status int) bool {
+	return status == http.StatusNotFound || status == http.StatusGone
+}
+
+// isRetryableStatus reports whether a failed request is worth retrying:
+// network errors (status 0), request timeouts, rate limiting and server errors
+func isRetryableStatus(status int) bool {
+	return status == 0 || status == http.StatusRequestTimeout || status == http.StatusTooManyRequests ||
+		status >= http.StatusInternalServerError
+}
+
+// contextTransport binds every scraper request to the run context,
+// so cancelling the run aborts in-flight requests
+type contextTransport struct {
+	ctx       context.Context
+	transport http.RoundTripper
+}
+
+func (t *contextTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	return t.transport.RoundTrip(req.WithContext(t.ctx))
+}
+
+// scraperResolver resolves AniDB IDs by scraping the external links of MAL anime pages
+type scraperResolver struct {
+	log      zerolog.Logger
+	config   *domain.Config
+	notifier domain.NotificationService
+}
+
+func newScraperResolver(log zerolog.Logger, config *domain.Config, notifier domain.NotificationService) *scraperResolver {
+	return &scraperResolver{
+		log:      log.With().Str("resolver", domain.AniDBResolverScraper).Logger(),
+		config:   config,
+		notifier: notifier,
+	}
+}
+
+func (r *scraperResolver) Name() string {
+	return domain.AniDBResolverScraper
+}
+
+func (r *scraperResolver) Provenance() domain.Provenance {
+	return domain.ProvenanceMALScrape
+}
+
+func (r *scraperResolver) Resolve(ctx context.Context, anime []domain.Anime, found func(malID, anidbID int)) ([]int, error) {
+	stats := newScrapeStats()
+
+	var mu sync.Mutex
+	failures := make(map[int]scrapeFailure)
+
+	// Use Colly for scraping (no file cache, using database cache instead).
+	// Revisits are allowed so failed pages can be retried.
+	cc := colly.NewCollector(
+		colly.AllowedDomains("myanimelist.net"),
+		colly.AllowURLRevisit(),
+	)
+
+	extensions.RandomUserAgent(cc)
+	cc.WithTransport(&contextTransport{ctx: ctx, transport: http.DefaultTransport})
+	cc.SetRequestTimeout(r.config.ScrapeTimeout)
+
+	cc.OnHTML("a[data-ga-click-type]", func(e *colly.HTMLElement) {
+		// Any external link proves the page layout is still what we expect
+		if strings.HasPrefix(e.Attr("data-ga-click-type"), "external-links-anime-pc") {
+			stats.externalLinks(e.Request.URL.String())
+		}
+	})
+
+	cc.OnHTML("a[href]", func(e *colly.HTMLElement) {
+		if e.Attr("data-ga-click-type") == "external-links-anime-pc-anidb" {
+			url := e.Attr("href")
+			m := anidbLinkRegex.FindStringSubmatch(url)
+			if len(m) < 2 {
+				return
+			}
+
+			anidbid, err := strconv.Atoi(m[1])
+			if err != nil {
+				r.log.Warn().Err(err).Str("url", url).Msg("failed to parse AniDB ID")
+				return
+			}
+
+			// Extract MAL ID from URL to find the right anime
+			if malID, ok := malIDFromURL(e.Request.URL.String()); ok {
+				r.log.Debug().Int("anidbid", anidbid).Int("malid", malID).Msg("Parsed AniDB ID")
+				stats.anidbLink()
+				found(malID, anidbid)
+			}
+		}
+	})
+
+	cc.Limit(&colly.LimitRule{
+		RandomDelay: r.config.ScrapeRandomDelay,
+		Delay:       r.config.ScrapeDelay,
+		Parallelism: r.config.ScrapeParallelism,
+		DomainGlob:  "*myanimelist*",
+	})
+
+	cc.OnRequest(func(req *colly.Request) {
+		r.log.Debug().Str("url", req.URL.String()).Msg("visiting")
+	})
+
+	cc.OnResponse(func(resp *colly.Response) {
+		stats.response(resp.StatusCode)
+	})
+
+	cc.OnError(func(resp *colly.Response, err error) {
+		// Requests aborted by cancellation say nothing about scraper health
+		if ctx.Err() != nil {
+			return
+		}
+
+		stats.error(resp.StatusCode)
+		r.log.Warn().Err(err).Int("status", resp.StatusCode).Str("url", resp.Request.URL.String()).Msg("MAL request failed")
+
+		if malID, ok := malIDFromURL(resp.Request.URL.String()); ok {
+			mu.Lock()
+			failures[malID] = scrapeFailure{status: resp.StatusCode, err: err, retryable: isRetryableStatus(resp.StatusCode)}
+			mu.Unlock()
+		}
+	})
+
+	pending := make([]int, 0, len(anime))
+	for _, v := range anime {
+		pending = append(pending, v.MalID)
+	}
+
+	var skipped []int
+	for round := 0; ; round++ {
+		skipped = r.visitAll(ctx, cc, pending, func(malID int, err error) {
+			mu.Lock()
+			defer mu.Unlock()
+			// Errors that did not reach OnError (e.g. invalid URL) are not worth retrying
+			if _, ok := failures[malID]; !ok {
+				failures[malID] = scrapeFailure{err: err}
+			}
+		})
+
+		if ctx.Err() != nil {
+			break
+		}
+
+		// Collect retryable failures into the retry queue
+		retry := []int{}
+		for malID, failure := range failures {
+			if failure.retryable {
+				retry = append(retry, malID)
+			}
+		}
+		if len(retry) == 0 || round >= r.config.ScrapeRetries {
+			break
+		}
+
+		backoff := time.Duration(round+1) * 5 * time.Second
+		r.log.Info().Int("count", len(retry)).Int("round", round+1).Dur("backoff", backoff).Msg("Retrying failed MAL pages")
+
+		select {
+		case <-ctx.Done():
+		case <-time.After(backoff):
+		}
+		if ctx.Err() != nil {
+			break
+		}
+
+		sort.Ints(retry)
+		for _, malID := range retry {
+			delete(failures, malID)
+		}
+		pending = retry
+	}
+
+	r.log.Info().Msgf("Scrape finished: %s", stats)
+
+	// Everything that failed or was never visited could not be checked,
+	// except pages MAL answered with 404 or 410: they are misses and backed off
+	failed := append([]int{}, skipped...)
+	for malID, failure := range failures {
+		if isMissingStatus(failure.status) {
+			r.log.Debug().Err(failure.err).Int("mal_id", malID).Int("status", failure.status).Msg("MAL page does not exist, counting as a miss")
+			continue
+		}
+		r.log.Debug().Err(failure.err).Int("mal_id", malID).Int("status", failure.status).Msg("MAL page could not be scraped")
+		failed = append(failed, malID)
+	}
+	sort.Ints(failed)
+
+	if err := ctx.Err(); err != nil {
+		r.log.Warn().Int("unchecked", len(failed)).Msg("Scrape cancelled")
+		return failed, err
+	}
+
+	if err := r.checkBreakage(ctx, stats); err != nil {
+		return failed, err
+	}
+
+	return failed, nil
+}
+
+// visitAll visits the MAL pages of the given IDs with a bounded number of workers.
+// It stops handing out pages as soon as ctx is cancelled and returns the IDs that were never visited.
+func (r *scraperResolver) visitAll(ctx context.Context, cc *colly.Collector, malIDs []int, visitErr func(malID int, err error)) []int {
+	jobs := make(chan int)
+
+	var wg sync.WaitGroup
+	for i := 0; i < r.config.ScrapeParallelism; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for malID := range jobs {
+				if err := cc.Visit(fmt.Sprintf("https://myanimelist.net/anime/%d", malID)); err != nil {
+					visitErr(malID, err)
+				}
+			}
+		}()
+	}
+
+	var skipped []int
+	for i, malID := range malIDs {
+		select {
+		case <-ctx.Done():
+			skipped = malIDs[i:]
+		case jobs <- malID:
+			continue
+		}
+		break
+	}
+
+	close(jobs)
+	wg.Wait()
+
+	return skipped
+}
+
+// avgPageResponseTime is a rough average response time of a MAL anime page, used for estimates
+const avgPageResponseTime = time.Second
+
+// estimateScrapeDuration estimates how long scraping the given number of MAL pages takes
+// with the configured parallelism and delays
+func estimateScrapeDuration(config *domain.Config, pages int) time.Duration {
+	perPage := config.ScrapeDelay + config.ScrapeRandomDelay/2 + avgPageResponseTime
+	rounds := (pages + config.ScrapeParallelism - 1) / config.ScrapeParallelism
+
+	return time.Duration(rounds) * perPage
+}
+
+// malIDFromURL extracts the MAL ID from a MAL anime page URL
+func malIDFromURL(url string) (int, bool) {
+	m := malURLRegex.FindStringSubmatch(url)
+	if len(m) < 2 {
+		return 0, false
+	}
+
+	malID, err := strconv.Atoi(m[1])
+	if err != nil {
+		return 0, false
+	}
+
+	return malID, true
+}
+
+// checkBreakage warns or fails when too few parsed pages contain an external links block,
+// which usually means MAL changed its markup, or when too many requests fail,
+// which usually means MAL is blocking the scraper (403/429/5xx)
+func (r *scraperResolver) checkBreakage(ctx context.Context, stats *scrapeStats) error {
+	hitRatio, errorRatio, visited := stats.ratios()
+	if visited < minBreakageSample {
+		return nil
+	}
+
+	// At least scrape_min_hit_ratio of the visited pages must have been answered
+	maxErrorRatio := 1 - r.config.ScrapeMinHitRatio
+
+	var message string
+	switch {
+	case errorRatio > maxErrorRatio:
+		message = fmt.Sprintf("%.1f%% of MAL page requests failed (threshold %.1f%%): %s",
+			errorRatio*100, maxErrorRatio*100, stats)
+
+		r.log.Error().
+			Float64("error_ratio", errorRatio).
+			Float64("max_error_ratio", maxErrorRatio).
+			Msg("MAL scraper looks broken, requests may be blocked")
+	case hitRatio < r.config.ScrapeMinHitRatio:
+		message = fmt.Sprintf("Only %.1f%% of scraped MAL pages contained an external links block (threshold %.1f%%): %s",
+			hitRatio*100, r.config.ScrapeMinHitRatio*100, stats)
+
+		r.log.Error().
+			Float64("hit_ratio", hitRatio).
+			Float64("min_hit_ratio", r.config.ScrapeMinHitRatio).
+			Msg("MAL scraper looks broken, page markup may have changed")
+	default:
+		return nil
+	}
+
+	if r.config.ScrapeBreakageAction == domain.ScrapeBreakageFail {
+		return errors.Wrap(ErrScraperBroken, message)
+	}
+
+	if r.notifier != nil {
+		if err := r.notifier.SendWarning(ctx, "MAL scraper looks broken", message); err != nil {
+			r.log.Warn().Err(err).Msg("Failed to send scraper breakage warning")
+		}
+	}
+
+	return nil
+}
@@ -6,29 +6,36 @@ import (
 	"fmt"
 	"io"
 	"net/http"
-	"regexp"
 	"sort"
-	"strconv"
+	"sync"
 	"time"
 
-	"github.com/gocolly/colly"
-	"github.com/gocolly/colly/extensions"
 	"github.com/pkg/errors"
 	"github.com/rs/zerolog"
 	"github.com/varoOP/shinkrodb/internal/domain"
 )
 
 type Service interface {
-	GetAnimeIDs(ctx context.Context, cacheRepo domain.CacheRepo) error
-	ScrapeAniDBIDs(ctx context.Context, cacheRepo domain.CacheRepo) error
+	// GetAnimeIDs stores the MAL IDs of the ranking and returns how many were stored
+	GetAnimeIDs(ctx context.Context, cacheRepo domain.CacheRepo) (int, *domain.TombstoneReport, error)
+	// ScrapeAniDBIDs stores the MAL IDs with their AniDB IDs and returns how many entries were stored
+	// and how many of them have an AniDB ID
+	ScrapeAniDBIDs(ctx context.Context, cacheRepo domain.CacheRepo) (int, int, error)
+	PlanAniDBIDs(ctx context.Context, cacheRepo domain.CacheRepo) (*domain.FetchPlan, error)
+	PlanAnimeIDs(ctx context.Context) (*domain.FetchPlan, error)
+	RefreshAniDBID(ctx context.Context, cacheRepo domain.CacheRepo, anime domain.Anime) (int, domain.Provenance, error)
 }
 
+// rankingPageSize is the number of entries requested per MAL ranking page
+const rankingPageSize = 500
+
 type service struct {
 	log       zerolog.Logger
 	config    *domain.Config
 	animeRepo domain.AnimeRepository
 	malIDPath domain.AnimePath
 	anidbPath domain.AnimePath
+	resolvers []domain.AniDBResolver
 }
 
 type MalResponse struct {
@@ -46,7 +53,9 @@ type MalResponse struct {
 				English  string   `json:"en"`
 				Japanese string   `json:"ja"`
 			} `json:"alternative_titles"`
-			StartDate string `json:"start_date"`
+			StartDate   string `json:"start_date"`
+			Status      string `json:"status"`
+			NumEpisodes int    `json:"num_episodes"`
 		} `json:"node"`
 		Ranking struct {
 			Rank int `json:"rank"`
@@ -70,33 +79,35 @@ func (c *clientIDTransport) RoundTrip(req *http.Request) (*http.Response, error)
 	return c.Transport.RoundTrip(req)
 }
 
-func NewService(log zerolog.Logger, config *domain.Config, animeRepo domain.AnimeRepository, malIDPath, anidbPath domain.AnimePath) Service {
+func NewService(log zerolog.Logger, config *domain.Config, animeRepo domain.AnimeRepository, notifier domain.NotificationService, malIDPath, anidbPath domain.AnimePath) Service {
+	log = log.With().Str("module", "mal").Logger()
 	return &service{
-		log:       log.With().Str("module", "mal").Logger(),
+		log:       log,
 		config:    config,
 		animeRepo: animeRepo,
 		malIDPath: malIDPath,
 		anidbPath: anidbPath,
+		resolvers: newResolvers(log, config, notifier),
 	}
 }
 
-func (s *service) GetAnimeIDs(ctx context.Context, cacheRepo domain.CacheRepo) error {
+func (s *service) GetAnimeIDs(ctx context.Context, cacheRepo domain.CacheRepo) (int, *domain.TombstoneReport, error) {
 	s.log.Info().Msg("Getting current ids from myanimelist..")
 	c := &http.Client{
 		Transport: &clientIDTransport{ClientID: s.config.MalClientID},
 	}
 
 	a := []domain.Anime{}
-	next, err := s.storeAnimeID(ctx, c, "https://api.myanimelist.net/v2/anime/ranking?ranking_type=all&limit=500&fields={media_type,start_date,alternative_titles}", &a)
+	next, err := s.storeAnimeID(ctx, c, fmt.Sprintf("https://api.myanimelist.net/v2/anime/ranking?ranking_type=all&limit=%d&fields={media_type,start_date,status,num_episodes,alternative_titles}", rankingPageSize), &a)
 	if err != nil {
-		return errors.Wrap(err, "failed to fetch initial MAL IDs")
+		return 0, nil, errors.Wrap(err, "failed to fetch initial MAL IDs")
 	}
 
 	for {
 		if next != "" {
 			next, err = s.storeAnimeID(ctx, c, next, &a)
 			if err != nil {
-				return errors.Wrap(err, "failed to fetch MAL IDs")
+				return 0, nil, errors.Wrap(err, "failed to fetch MAL IDs")
 			}
 		} else {
 			break
@@ -107,11 +118,23 @@ func (s *service) GetAnimeIDs(ctx context.Context, cacheRepo domain.CacheRepo) e
 		return a[i].MalID < a[j].MalID
 	})
 
-	// Update mal_cache table with all MAL IDs
+	report := &domain.TombstoneReport{}
+
+	// Update mal_cache table with all MAL IDs.
+	// The ranking is complete at this point, so the cache is updated even if the run is cancelled meanwhile.
 	if cacheRepo != nil {
+		writeCtx := context.WithoutCancel(ctx)
+
+		// Tombstone cached MAL IDs that are no longer returned by the ranking.
+		// This has to happen before the upserts below, which clear the tombstone of returned IDs.
+		report, err = s.reconcileTombstones(writeCtx, cacheRepo, a)
+		if err != nil {
+			return 0, nil, errors.Wrap(err, "failed to reconcile tombstoned MAL IDs")
+		}
+
 		for _, anime := range a {
 			url := fmt.Sprintf("https://myanimelist.net/anime/%d", anime.MalID)
-			if err := cacheRepo.UpsertMAL(ctx, anime.MalID, url, anime.ReleaseDate, anime.Type); err != nil {
+			if err := cacheRepo.UpsertMAL(writeCtx, anime.MalID, url, anime.ReleaseDate, anime.Type, anime.Status, anime.Episodes, anime.JapaneseTitle, anime.Synonyms); err != nil {
 				s.log.Warn().Err(err).Int("mal_id", anime.MalID).Msg("failed to update MAL cache")
 			}
 		}
@@ -119,11 +142,102 @@ func (s *service) GetAnimeIDs(ctx context.Context, cacheRepo domain.CacheRepo) e
 	}
 
 	if err := s.animeRepo.Store(ctx, s.malIDPath, a); err != nil {
-		return errors.Wrap(err, "failed to store MAL IDs")
+		return 0, nil, errors.Wrap(err, "failed to store MAL IDs")
 	}
 	s.log.Info().Str("path", string(s.malIDPath)).Msg("Stored malids")
 
-	return nil
+	return len(a), report, nil
+}
+
+// PlanAnimeIDs estimates the requests GetAnimeIDs makes from the MAL IDs of the previous run.
+// The ranking itself is not fetched, so its output is unknown.
+func (s *service) PlanAnimeIDs(ctx context.Context) (*domain.FetchPlan, error) {
+	plan := &domain.FetchPlan{Note: "the MAL ranking is not fetched in a dry run"}
+
+	a, err := s.animeRepo.Get(ctx, s.malIDPath)
+	if err != nil {
+		plan.Requests = 1
+		plan.Note = "no previous MAL IDs, request count unknown"
+		return plan, nil
+	}
+
+	plan.Candidates = len(a)
+	plan.Requests = (len(a) + rankingPageSize - 1) / rankingPageSize
+	plan.Duration = time.Duration(plan.Requests) * avgPageResponseTime
+
+	return plan, nil
+}
+
+// reconcileTombstones diffs the fetched MAL IDs against mal_cache.
+// Cached IDs missing from the ranking are tombstoned, and purged with DeleteMAL
+// once they have been tombstoned for longer than the configured grace period.
+func (s *service) reconcileTombstones(ctx context.Context, cacheRepo domain.CacheRepo, fetched []domain.Anime) (*domain.TombstoneReport, error) {
+	report := &domain.TombstoneReport{}
+
+	// An empty ranking means something went wrong upstream, never tombstone the whole cache
+	if len(fetched) == 0 {
+		s.log.Warn().Msg("MAL ranking returned no entries, skipping tombstone check")
+		return report, nil
+	}
+
+	entries, err := cacheRepo.GetMALEntries(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get MAL cache entries")
+	}
+
+	fetchedIDs := make(map[int]bool, len(fetched))
+	for _, anime := range fetched {
+		fetchedIDs[anime.MalID] = true
+	}
+
+	grace := time.Duration(s.config.TombstoneGraceDays) * 24 * time.Hour
+	now := time.Now()
+
+	for _, entry := range entries {
+		if fetchedIDs[entry.MalID] {
+			if entry.TombstonedAt != "" {
+				report.Restored = append(report.Restored, entry.MalID)
+				s.log.Info().Int("mal_id", entry.MalID).Msg("Tombstoned MAL ID returned to the ranking")
+			}
+			continue
+		}
+
+		if entry.TombstonedAt == "" {
+			if err := cacheRepo.TombstoneMAL(ctx, entry.MalID); err != nil {
+				return nil, errors.Wrapf(err, "failed to tombstone MAL ID %d", entry.MalID)
+			}
+			report.Tombstoned = append(report.Tombstoned, entry.MalID)
+			s.log.Info().Int("mal_id", entry.MalID).Msg("MAL ID missing from ranking, tombstoned")
+			continue
+		}
+
+		tombstonedAt, err := time.Parse(time.RFC3339, entry.TombstonedAt)
+		if err != nil {
+			s.log.Warn().Err(err).Int("mal_id", entry.MalID).Str("tombstoned_at", entry.TombstonedAt).Msg("failed to parse tombstone timestamp")
+			report.Pending = append(report.Pending, entry.MalID)
+			continue
+		}
+
+		if now.Sub(tombstonedAt) < grace {
+			report.Pending = append(report.Pending, entry.MalID)
+			continue
+		}
+
+		if err := cacheRepo.DeleteMAL(ctx, entry.MalID); err != nil {
+			return nil, errors.Wrapf(err, "failed to purge MAL ID %d", entry.MalID)
+		}
+		report.Purged = append(report.Purged, entry.MalID)
+		s.log.Info().Int("mal_id", entry.MalID).Str("tombstoned_at", entry.TombstonedAt).Msg("Purged tombstoned MAL ID after grace period")
+	}
+
+	s.log.Info().
+		Int("tombstoned", len(report.Tombstoned)).
+		Int("pending", len(report.Pending)).
+		Int("restored", len(report.Restored)).
+		Int("purged", len(report.Purged)).
+		Msg("Tombstone check complete")
+
+	return report, nil
 }
 
 func (s *service) storeAnimeID(ctx context.Context, c *http.Client, url string, a *[]domain.Anime) (string, error) {
@@ -163,17 +277,21 @@ func (s *service) storeAnimeID(ctx context.Context, c *http.Client, url string,
 			MalID:         v.Node.ID,
 			Type:          v.Node.MediaType,
 			ReleaseDate:   v.Node.StartDate,
+			Status:        v.Node.Status,
+			Episodes:      v.Node.NumEpisodes,
 		})
 	}
 
 	return mal.Paging.Next, nil
 }
 
-func (s *service) ScrapeAniDBIDs(ctx context.Context, cacheRepo domain.CacheRepo) error {
+// prepareScrape loads malid.json, applies cached AniDB IDs and selects the entries to scrape.
+// It returns the anime list, the MAL IDs with a cached AniDB ID and the entries to scrape.
+func (s *service) prepareScrape(ctx context.Context, cacheRepo domain.CacheRepo) ([]domain.Anime, map[int]bool, []domain.Anime, error) {
 	// Get anime list from malid.json
 	a, err := s.animeRepo.Get(ctx, s.malIDPath)
 	if err != nil {
-		return errors.Wrap(err, "failed to get anime list")
+		return nil, nil, nil, errors.Wrap(err, "failed to get anime list")
 	}
 
 	// Get all cached entries with AniDB IDs (regardless of release date)
@@ -197,19 +315,72 @@ func (s *service) ScrapeAniDBIDs(ctx context.Context, cacheRepo domain.CacheRepo
 		}
 	}
 
-	// Filter entries to scrape based on configured scrape mode
-	toScrape := s.filterAnimeToScrape(a, cachedMalIDs)
+	// Load airing status and previous scrape attempts for the scrape policy
+	statuses := make(map[int]string)
+	var attempts map[int]*domain.ScrapeAttempt
+	if cacheRepo != nil {
+		entries, err := cacheRepo.GetMALEntries(ctx)
+		if err != nil {
+			s.log.Warn().Err(err).Msg("failed to get MAL cache entries, scrape policy will ignore airing status")
+		}
+		for _, entry := range entries {
+			statuses[entry.MalID] = entry.Status
+		}
+
+		attempts, err = cacheRepo.GetScrapeAttempts(ctx)
+		if err != nil {
+			s.log.Warn().Err(err).Msg("failed to get scrape attempts, scrape policy will ignore previous misses")
+		}
+	}
+
+	// Filter entries to scrape based on configured scrape mode and policy
+	policy := newScrapePolicy(s.config, time.Now(), statuses, attempts)
+	toScrape := s.filterAnimeToScrape(a, cachedMalIDs, policy)
+
+	return a, cachedMalIDs, toScrape, nil
+}
+
+// PlanAniDBIDs reports which entries ScrapeAniDBIDs would resolve, without making any requests
+func (s *service) PlanAniDBIDs(ctx context.Context, cacheRepo domain.CacheRepo) (*domain.FetchPlan, error) {
+	a, _, toScrape, err := s.prepareScrape(ctx, cacheRepo)
+	if err != nil {
+		return nil, err
+	}
+
+	plan := &domain.FetchPlan{
+		Candidates: len(a),
+		ToFetch:    toScrape,
+		Output:     a,
+	}
+
+	// Only the scraper makes requests, the other resolvers work offline
+	for _, resolver := range s.resolvers {
+		if resolver.Name() == domain.AniDBResolverScraper {
+			plan.Requests = len(toScrape)
+			plan.Duration = estimateScrapeDuration(s.config, plan.Requests)
+			plan.Note = "one MAL page per entry, excluding retries"
+		}
+	}
+	if plan.Requests == 0 && len(toScrape) > 0 {
+		plan.Note = "resolved offline, the scraper is not enabled"
+	}
+
+	return plan, nil
+}
+
+func (s *service) ScrapeAniDBIDs(ctx context.Context, cacheRepo domain.CacheRepo) (int, int, error) {
+	a, cachedMalIDs, toScrape, err := s.prepareScrape(ctx, cacheRepo)
+	if err != nil {
+		return 0, 0, err
+	}
 
 	if len(toScrape) == 0 {
 		s.log.Info().Msg("All anime already cached, skipping scrape")
 		// Still store the updated list with cached AniDB IDs
-		if err := s.animeRepo.Store(ctx, s.anidbPath, a); err != nil {
-			return errors.Wrap(err, "failed to store AniDB IDs")
-		}
-		return nil
+		return s.storeAniDBIDs(ctx, a)
 	}
 
-	s.log.Info().Int("total", len(a)).Int("cached", len(cachedMalIDs)).Int("to_scrape", len(toScrape)).Msg("Starting scrape")
+	s.log.Info().Int("total", len(a)).Int("cached", len(cachedMalIDs)).Int("to_scrape", len(toScrape)).Msg("Starting AniDB ID resolution")
 
 	// Build map for O(1) MAL ID lookup
 	malIDToIndex := make(map[int]int, len(a))
@@ -217,99 +388,180 @@ func (s *service) ScrapeAniDBIDs(ctx context.Context, cacheRepo domain.CacheRepo
 		malIDToIndex[a[i].MalID] = i
 	}
 
-	// Use Colly for scraping (no file cache, using database cache instead)
-	cc := colly.NewCollector(
-		colly.AllowedDomains("myanimelist.net"),
-	)
+	// MAL IDs resolved to an AniDB ID during this run, and MAL IDs that could not be checked
+	found := make(map[int]bool, len(toScrape))
+	unchecked := make(map[int]bool)
+	var mu sync.Mutex
+	var fatalErr error
+
+	// Cache writes must finish even when the run is cancelled, so resolved IDs are not lost
+	writeCtx := context.WithoutCancel(ctx)
+
+	// Try each configured resolver in order, passing on the entries that are still unresolved
+	for _, resolver := range s.resolvers {
+		pending := make([]domain.Anime, 0, len(toScrape))
+		for _, v := range toScrape {
+			if !found[v.MalID] {
+				pending = append(pending, v)
+			}
+		}
+		if len(pending) == 0 {
+			break
+		}
 
-	extensions.RandomUserAgent(cc)
+		s.log.Info().Str("resolver", resolver.Name()).Int("pending", len(pending)).Msg("Resolving AniDB IDs")
 
-	r := regexp.MustCompile(`aid=(\d+)`)
-	cc.OnHTML("a[href]", func(e *colly.HTMLElement) {
-		if e.Attr("data-ga-click-type") == "external-links-anime-pc-anidb" {
-			url := e.Attr("href")
-			m := r.FindStringSubmatch(url)
-			if len(m) < 2 {
-				return
-			}
+		provenance := resolver.Provenance()
+		failed, err := resolver.Resolve(ctx, pending, func(malID, anidbID int) {
+			mu.Lock()
+			defer mu.Unlock()
 
-			anidbid, err := strconv.Atoi(m[1])
-			if err != nil {
-				s.log.Warn().Err(err).Str("url", url).Msg("failed to parse AniDB ID")
+			// O(1) lookup using map instead of O(n) linear search
+			i, ok := malIDToIndex[malID]
+			if !ok {
 				return
 			}
+			a[i].AnidbID = anidbID
+			found[malID] = true
+
+			// Update AniDB cache immediately when AniDB ID is found
+			// Note: mal_cache should only be updated in GetAnimeIDs, not here
+			if cacheRepo != nil {
+				if err := cacheRepo.UpsertAniDB(writeCtx, malID, anidbID, provenance); err != nil {
+					s.log.Warn().Err(err).Int("mal_id", malID).Msg("failed to update AniDB cache")
+				} else {
+					s.log.Debug().Int("mal_id", malID).Int("anidb_id", anidbID).Str("provenance", string(provenance)).Msg("Updated AniDB cache")
+				}
+			}
+		})
+		for _, malID := range failed {
+			unchecked[malID] = true
+		}
 
-			// Extract MAL ID from URL to find the right anime
-			malIDMatch := regexp.MustCompile(`/anime/(\d+)`).FindStringSubmatch(e.Request.URL.String())
-			if len(malIDMatch) >= 2 {
-				malID, _ := strconv.Atoi(malIDMatch[1])
-				// O(1) lookup using map instead of O(n) linear search
-				if i, found := malIDToIndex[malID]; found {
-					a[i].AnidbID = anidbid
-					s.log.Debug().Int("anidbid", anidbid).Int("malid", malID).Msg("Parsed AniDB ID")
-
-					// Update AniDB cache immediately when AniDB ID is found
-					// Note: mal_cache should only be updated in GetAnimeIDs, not here
-					if cacheRepo != nil {
-						if err := cacheRepo.UpsertAniDB(ctx, malID, anidbid); err != nil {
-							s.log.Warn().Err(err).Int("mal_id", malID).Msg("failed to update AniDB cache")
-						} else {
-							s.log.Debug().Int("mal_id", malID).Int("anidb_id", anidbid).Msg("Updated AniDB cache")
-						}
-					}
+		if err != nil {
+			if errors.Is(err, ErrScraperBroken) {
+				// Pages of a broken scraper say nothing about the entries, don't count them as misses
+				for _, v := range pending {
+					unchecked[v.MalID] = true
 				}
+				fatalErr = err
+				break
+			}
+			if ctx.Err() != nil {
+				fatalErr = err
+				break
 			}
+			s.log.Warn().Err(err).Str("resolver", resolver.Name()).Msg("AniDB resolver failed, trying next resolver")
 		}
-	})
+	}
 
-	// Since to_scrape count is expected to be low, use higher parallelism and lower delays
-	cc.Limit(&colly.LimitRule{
-		RandomDelay: 1 * time.Second,
-		Delay:       1 * time.Second,
-		Parallelism: 30,
-		DomainGlob:  "*myanimelist*",
-	})
+	// Record attempts so the scrape policy can back off entries that keep missing.
+	// Entries that could not be checked are not counted as misses.
+	if cacheRepo != nil {
+		for _, v := range toScrape {
+			if unchecked[v.MalID] && !found[v.MalID] {
+				continue
+			}
+			if err := cacheRepo.RecordScrapeAttempt(writeCtx, v.MalID, found[v.MalID]); err != nil {
+				s.log.Warn().Err(err).Int("mal_id", v.MalID).Msg("failed to record scrape attempt")
+			}
+		}
+	}
 
-	cc.OnRequest(func(r *colly.Request) {
-		s.log.Debug().Str("url", r.URL.String()).Msg("visiting")
-	})
+	s.log.Info().Int("to_scrape", len(toScrape)).Int("resolved", len(found)).Int("unchecked", len(unchecked)).Msg("AniDB ID resolution complete")
 
-	// Only scrape entries not in cache
-	for _, v := range toScrape {
-		cc.Visit(fmt.Sprintf("https://myanimelist.net/anime/%d", v.MalID))
-	}
+	// Entries without AniDB IDs are not cached - they'll be retried according to the scrape policy
 
-	// Wait for scraping to complete
-	cc.Wait()
+	if fatalErr != nil {
+		return 0, 0, errors.Wrap(fatalErr, "AniDB ID resolution stopped")
+	}
 
-	// Note: Entries with AniDB IDs were already updated in OnHTML callback
-	// Entries without AniDB IDs are not cached - they'll be scraped again next time
+	return s.storeAniDBIDs(ctx, a)
+}
 
+// storeAniDBIDs stores a and returns the number of entries and of entries with an AniDB ID
+func (s *service) storeAniDBIDs(ctx context.Context, a []domain.Anime) (int, int, error) {
 	if err := s.animeRepo.Store(ctx, s.anidbPath, a); err != nil {
-		return errors.Wrap(err, "failed to store AniDB IDs")
+		return 0, 0, errors.Wrap(err, "failed to store AniDB IDs")
+	}
+
+	withAniDB := 0
+	for _, anime := range a {
+		if anime.AnidbID > 0 {
+			withAniDB++
+		}
 	}
 
-	return nil
+	return len(a), withAniDB, nil
 }
 
-// filterAnimeToScrape filters anime list based on configured AniDB mode
-func (s *service) filterAnimeToScrape(animeList []domain.Anime, cachedMalIDs map[int]bool) []domain.Anime {
+// RefreshAniDBID re-resolves the AniDB ID of a single entry with the configured resolvers,
+// ignoring the fetch mode and scrape policy, and updates the cache.
+// It returns 0 if no resolver found an AniDB ID.
+func (s *service) RefreshAniDBID(ctx context.Context, cacheRepo domain.CacheRepo, anime domain.Anime) (int, domain.Provenance, error) {
+	// Cache writes must finish even when the refresh is cancelled
+	writeCtx := context.WithoutCancel(ctx)
+
+	// Entries that could not be checked are not counted as misses
+	unchecked := false
+	for _, resolver := range s.resolvers {
+		anidbID := 0
+		failed, err := resolver.Resolve(ctx, []domain.Anime{anime}, func(malID, id int) {
+			if malID == anime.MalID {
+				anidbID = id
+			}
+		})
+		if len(failed) > 0 {
+			unchecked = true
+		}
+		if err != nil {
+			if errors.Is(err, ErrScraperBroken) || ctx.Err() != nil {
+				return 0, "", errors.Wrap(err, "AniDB ID resolution stopped")
+			}
+			s.log.Warn().Err(err).Str("resolver", resolver.Name()).Msg("AniDB resolver failed, trying next resolver")
+			continue
+		}
+		if anidbID == 0 {
+			continue
+		}
+
+		provenance := resolver.Provenance()
+		if cacheRepo != nil {
+			if err := cacheRepo.UpsertAniDB(writeCtx, anime.MalID, anidbID, provenance); err != nil {
+				return 0, "", errors.Wrap(err, "failed to update AniDB cache")
+			}
+			if err := cacheRepo.RecordScrapeAttempt(writeCtx, anime.MalID, true); err != nil {
+				s.log.Warn().Err(err).Int("mal_id", anime.MalID).Msg("failed to record scrape attempt")
+			}
+		}
+
+		return anidbID, provenance, nil
+	}
+
+	if cacheRepo != nil && !unchecked {
+		if err := cacheRepo.RecordScrapeAttempt(writeCtx, anime.MalID, false); err != nil {
+			s.log.Warn().Err(err).Int("mal_id", anime.MalID).Msg("failed to record scrape attempt")
+		}
+	}
+
+	return 0, "", nil
+}
+
+// filterAnimeToScrape filters anime list based on configured AniDB mode and orders it by the scrape policy
+func (s *service) filterAnimeToScrape(animeList []domain.Anime, cachedMalIDs map[int]bool, policy *scrapePolicy) []domain.Anime {
 	// Skip scraping if mode is set to skip
 	if s.config.AniDBMode == domain.FetchModeSkip {
 		return []domain.Anime{}
 	}
 
-	toScrape := []domain.Anime{}
-	currentYear := time.Now().Year()
-	oneYearAgoYear := currentYear - 1
+	candidates := []domain.Anime{}
+	notDue := 0
 
 	for _, anime := range animeList {
-		shouldScrape := false
-
 		switch s.config.AniDBMode {
 		case domain.FetchModeAll:
 			// Scrape everything, even if already has AniDB ID in cache
-			shouldScrape = true
+			candidates = append(candidates, anime)
 
 		case domain.FetchModeMissing:
 			// Scrape all entries without AniDB ID (no filters)
@@ -317,41 +569,32 @@ func (s *service) filterAnimeToScrape(animeList []domain.Anime, cachedMalIDs map
 			if cachedMalIDs[anime.MalID] || anime.AnidbID > 0 {
 				continue
 			}
-			shouldScrape = true
+			candidates = append(candidates, anime)
 
 		case domain.FetchModeDefault:
 			// Default: only scrape entries that:
 			// - Don't have AniDB ID
-			// - Released in the last year
-			// - Type = "tv"
-			// Skip if already cached with AniDB ID
+			// - Are due according to the scrape policy (airing status, start date, type and previous misses)
 			if cachedMalIDs[anime.MalID] || anime.AnidbID > 0 {
 				continue
 			}
 
-			if anime.Type != "tv" {
-				shouldScrape = false
-				break
-			}
-
-			if anime.ReleaseDate != "" {
-				// Extract year from release_date (format: "YYYY-MM-DD", "YYYY-MM", or "YYYY")
-				if len(anime.ReleaseDate) >= 4 {
-					releaseYearStr := anime.ReleaseDate[:4]
-					if releaseYear, parseErr := strconv.Atoi(releaseYearStr); parseErr == nil {
-						shouldScrape = releaseYear >= oneYearAgoYear
-					}
-				}
-			} else {
-				// If no release date, don't scrape (too old or unknown)
-				shouldScrape = false
+			if !policy.isDue(anime) {
+				notDue++
+				continue
 			}
-		}
-
-		if shouldScrape {
-			toScrape = append(toScrape, anime)
+			candidates = append(candidates, anime)
 		}
 	}
 
+	toScrape := policy.prioritize(candidates)
+
+	s.log.Info().
+		Int("candidates", len(candidates)).
+		Int("not_due", notDue).
+		Int("over_budget", len(candidates)-len(toScrape)).
+		Int("max_scrapes", s.config.MaxScrapes).
+		Msg("Scrape policy applied")
+
 	return toScrape
 }
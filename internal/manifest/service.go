@@ -0,0 +1,186 @@
+package manifest
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/klauspost/compress/gzip"
+	"github.com/klauspost/compress/zstd"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog"
+	"github.com/varoOP/shinkrodb/internal/domain"
+	_ "modernc.org/sqlite"
+)
+
+type Service interface {
+	// Generate describes the output files and stores the manifest.
+	// animeListSHA256 is the hash of the anime-list.xml the outputs were built from, empty if it is unknown.
+	Generate(ctx context.Context, animeListSHA256 string) (*domain.Manifest, error)
+}
+
+type service struct {
+	log          zerolog.Logger
+	build        domain.BuildInfo
+	manifestRepo domain.ManifestRepository
+	manifestPath string
+	files        []string
+}
+
+// NewService creates a manifest service that describes files and stores the manifest at manifestPath
+func NewService(log zerolog.Logger, build domain.BuildInfo, manifestRepo domain.ManifestRepository, manifestPath string, files []string) Service {
+	return &service{
+		log:          log.With().Str("module", "manifest").Logger(),
+		build:        build,
+		manifestRepo: manifestRepo,
+		manifestPath: manifestPath,
+		files:        files,
+	}
+}
+
+// Generate describes the output files that exist and stores the manifest.
+// Files that were not generated yet are left out.
+func (s *service) Generate(ctx context.Context, animeListSHA256 string) (*domain.Manifest, error) {
+	manifest := &domain.Manifest{
+		SchemaVersion: domain.OutputSchemaVersion,
+		GeneratedAt:   time.Now().UTC().Format(time.RFC3339),
+		Shinkrodb:     s.build,
+		Files:         []domain.ManifestFile{},
+	}
+	manifest.Sources.AnimeListSHA256 = animeListSHA256
+
+	if animeListSHA256 == "" {
+		s.log.Warn().Msg("anime-list.xml was not loaded, manifest will not include its hash")
+	}
+
+	for _, path := range s.files {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return nil, errors.Wrapf(err, "failed to read %s", path)
+		}
+
+		file, err := describe(ctx, path, data)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to describe %s", path)
+		}
+		manifest.Files = append(manifest.Files, file)
+	}
+
+	if err := s.manifestRepo.StoreManifest(ctx, s.manifestPath, manifest); err != nil {
+		return nil, errors.Wrap(err, "failed to store manifest")
+	}
+
+	s.log.Info().Int("files", len(manifest.Files)).Msg("Manifest generated")
+	return manifest, nil
+}
+
+// describe returns the manifest entry of an output file.
+// Compressed variants count the records of their decompressed content, deltas count their patch operations.
+func describe(ctx context.Context, path string, data []byte) (domain.ManifestFile, error) {
+	sum := sha256.Sum256(data)
+	file := domain.ManifestFile{
+		Name:   filepath.Base(path),
+		Size:   int64(len(data)),
+		SHA256: hex.EncodeToString(sum[:]),
+	}
+
+	content, contentPath := data, path
+	switch filepath.Ext(path) {
+	case domain.CompressionGzip.Extension():
+		r, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return file, err
+		}
+		if content, err = io.ReadAll(r); err != nil {
+			return file, err
+		}
+		file.Compression = domain.CompressionGzip
+		contentPath = strings.TrimSuffix(path, domain.CompressionGzip.Extension())
+
+	case domain.CompressionZstd.Extension():
+		r, err := zstd.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return file, err
+		}
+		defer r.Close()
+		if content, err = io.ReadAll(r); err != nil {
+			return file, err
+		}
+		file.Compression = domain.CompressionZstd
+		contentPath = strings.TrimSuffix(path, domain.CompressionZstd.Extension())
+	}
+
+	if strings.HasSuffix(path, ".delta.json") {
+		var delta domain.ReleaseDelta
+		if err := json.Unmarshal(content, &delta); err != nil {
+			return file, err
+		}
+		file.Records = len(delta.Patch)
+		file.BaseSHA256 = delta.BaseSHA256
+		return file, nil
+	}
+
+	records, err := countRecords(ctx, contentPath, content)
+	if err != nil {
+		return file, err
+	}
+	file.Records = records
+
+	return file, nil
+}
+
+// countRecords counts the records of an output file by its format: the entries of a JSON array,
+// the keys of a JSON object (indexes), the lines of NDJSON, the rows of CSV without the header,
+// or the rows of the anime table of a SQLite export
+func countRecords(ctx context.Context, path string, data []byte) (int, error) {
+	switch filepath.Ext(path) {
+	case domain.OutputFormatNDJSON.Extension():
+		return bytes.Count(data, []byte("\n")), nil
+
+	case domain.OutputFormatCSV.Extension():
+		rows, err := csv.NewReader(bytes.NewReader(data)).ReadAll()
+		if err != nil {
+			return 0, err
+		}
+		return max(len(rows)-1, 0), nil
+
+	case domain.OutputFormatSQLite.Extension():
+		db, err := sql.Open("sqlite", "file:"+path+"?mode=ro")
+		if err != nil {
+			return 0, err
+		}
+		defer db.Close()
+
+		var count int
+		if err := db.QueryRowContext(ctx, "SELECT COUNT(*) FROM anime").Scan(&count); err != nil {
+			return 0, err
+		}
+		return count, nil
+	}
+
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '{' {
+		var object map[string]json.RawMessage
+		if err := json.Unmarshal(data, &object); err != nil {
+			return 0, err
+		}
+		return len(object), nil
+	}
+
+	var array []json.RawMessage
+	if err := json.Unmarshal(data, &array); err != nil {
+		return 0, err
+	}
+	return len(array), nil
+}
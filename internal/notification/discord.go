@@ -68,6 +68,11 @@ func (s *DiscordService) SendSuccess(ctx context.Context, stats domain.Statistic
 				Value:  fmt.Sprintf("%d", stats.DupeCount),
 				Inline: true,
 			},
+			{
+				Name:   "Tombstoned MAL IDs",
+				Value:  fmt.Sprintf("%d new, %d pending, %d restored, %d purged", stats.TombstonedMALIDs, stats.PendingMALIDs, stats.RestoredMALIDs, stats.PurgedMALIDs),
+				Inline: false,
+			},
 		},
 	}
 
@@ -98,6 +103,26 @@ func (s *DiscordService) SendError(ctx context.Context, err error) error {
 	return s.sendWebhook(ctx, payload)
 }
 
+// SendWarning sends a warning notification
+func (s *DiscordService) SendWarning(ctx context.Context, title, message string) error {
+	if s.webhookURL == "" {
+		return nil // No webhook configured, skip silently
+	}
+
+	embed := discordEmbed{
+		Title:       fmt.Sprintf("ShinkroDB Warning: %s", title),
+		Description: message,
+		Color:       0xffa500, // Orange
+		Timestamp:   time.Now().Format(time.RFC3339),
+	}
+
+	payload := discordWebhook{
+		Embeds: []discordEmbed{embed},
+	}
+
+	return s.sendWebhook(ctx, payload)
+}
+
 // sendWebhook sends a webhook payload to Discord
 func (s *DiscordService) sendWebhook(ctx context.Context, payload discordWebhook) error {
 	jsonData, err := json.Marshal(payload)
@@ -45,3 +45,12 @@ func (s *Service) SendError(ctx context.Context, err error) error {
 	return nil
 }
 
+// SendWarning sends warning notifications through all configured channels
+func (s *Service) SendWarning(ctx context.Context, title, message string) error {
+	if s.discord != nil {
+		if err := s.discord.SendWarning(ctx, title, message); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,463 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"iter"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/varoOP/shinkrodb/internal/domain"
+)
+
+// Stage is a single step of the run pipeline.
+// Stages declare the intermediate files they read and write; the runner derives
+// the execution order from them, so a new enrichment source only has to be registered.
+type Stage interface {
+	Name() domain.Stage
+	Inputs() []domain.AnimePath
+	Outputs() []domain.AnimePath
+	Run(ctx context.Context, result *Result) error
+}
+
+// Enricher is a stage that only fills its own fields of the entries it reads.
+// Enrich streams the enricher's input to look the fields up, the output is then written while
+// the input is streamed again with the fields filled in, so the list is never held in memory.
+// Selected enrichers that form a chain (each reading the output of the previous one) look their
+// fields up concurrently from the chain's input instead of waiting for each other's output.
+type Enricher interface {
+	Stage
+	// Enrich looks up the enricher's fields for the entries of anime and returns a function filling them in
+	Enrich(ctx context.Context, anime iter.Seq2[domain.Anime, error], result *Result) (func(*domain.Anime), error)
+}
+
+// Result holds the outcome of a single stage
+type Result struct {
+	Stage    domain.Stage
+	Skipped  bool
+	Duration time.Duration
+	Counters map[string]int
+}
+
+// Set records a stage counter, e.g. the number of IDs found
+func (r *Result) Set(name string, value int) {
+	if r.Counters == nil {
+		r.Counters = make(map[string]int)
+	}
+	r.Counters[name] = value
+}
+
+// Get returns a stage counter, 0 if it was not recorded or r is nil
+func (r *Result) Get(name string) int {
+	if r == nil {
+		return 0
+	}
+	return r.Counters[name]
+}
+
+// Results holds the results of a pipeline run in execution order
+type Results []*Result
+
+// Get returns the result of a stage, nil if the stage is not part of the pipeline
+func (r Results) Get(stage domain.Stage) *Result {
+	for _, result := range r {
+		if result.Stage == stage {
+			return result
+		}
+	}
+
+	return nil
+}
+
+// Runner orders and runs pipeline stages
+type Runner struct {
+	log       zerolog.Logger
+	animeRepo domain.AnimeRepository
+	stages    []Stage
+}
+
+// NewRunner creates a runner for the given stages.
+// Stages are ordered so every stage runs after the stages producing its inputs;
+// stages without such a dependency keep their registration order.
+// animeRepo is used to read and write the files of enricher chains.
+func NewRunner(log zerolog.Logger, animeRepo domain.AnimeRepository, stages ...Stage) (*Runner, error) {
+	ordered, err := order(stages)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Runner{
+		log:       log.With().Str("module", "pipeline").Logger(),
+		animeRepo: animeRepo,
+		stages:    ordered,
+	}, nil
+}
+
+// Stages returns the names of all stages in execution order
+func (r *Runner) Stages() []domain.Stage {
+	names := make([]domain.Stage, 0, len(r.stages))
+	for _, stage := range r.stages {
+		names = append(names, stage.Name())
+	}
+
+	return names
+}
+
+// Stage returns the stage with the given name, nil if it is not part of the pipeline
+func (r *Runner) Stage(name domain.Stage) Stage {
+	for _, stage := range r.stages {
+		if stage.Name() == name {
+			return stage
+		}
+	}
+
+	return nil
+}
+
+// Select returns the stages to run, in execution order.
+// Only limits the run to the given stages (all stages if empty); From and Until
+// trim the selection to a range of the pipeline.
+func (r *Runner) Select(selection domain.StageSelection) ([]domain.Stage, error) {
+	names := r.Stages()
+
+	index := func(stage domain.Stage) (int, error) {
+		for i, name := range names {
+			if name == stage {
+				return i, nil
+			}
+		}
+		return -1, fmt.Errorf("invalid stage: %s (must be one of %s)", stage, joinStages(names))
+	}
+
+	only := make(map[domain.Stage]bool)
+	for _, stage := range selection.Only {
+		if _, err := index(stage); err != nil {
+			return nil, err
+		}
+		only[stage] = true
+	}
+
+	first, last := 0, len(names)-1
+	if selection.From != "" {
+		i, err := index(selection.From)
+		if err != nil {
+			return nil, err
+		}
+		first = i
+	}
+	if selection.Until != "" {
+		i, err := index(selection.Until)
+		if err != nil {
+			return nil, err
+		}
+		last = i
+	}
+	if first > last {
+		return nil, fmt.Errorf("invalid stage range: %s comes after %s", selection.From, selection.Until)
+	}
+
+	selected := []domain.Stage{}
+	for i := first; i <= last; i++ {
+		if len(only) == 0 || only[names[i]] {
+			selected = append(selected, names[i])
+		}
+	}
+
+	if len(selected) == 0 {
+		return nil, fmt.Errorf("no stages selected")
+	}
+
+	return selected, nil
+}
+
+// Run runs the selected stages in order and skips the others.
+// Inputs of selected stages that are not produced by an earlier selected stage
+// must be stored by a previous run; this is checked before anything runs.
+func (r *Runner) Run(ctx context.Context, selected []domain.Stage) (Results, error) {
+	run := make(map[domain.Stage]bool, len(selected))
+	for _, name := range selected {
+		run[name] = true
+	}
+
+	if err := r.checkInputs(ctx, run); err != nil {
+		return nil, err
+	}
+
+	results := make(Results, 0, len(r.stages))
+	for i := 0; i < len(r.stages); i++ {
+		stage := r.stages[i]
+
+		if !run[stage.Name()] {
+			results = append(results, &Result{Stage: stage.Name(), Skipped: true})
+			r.log.Info().Str("stage", string(stage.Name())).Msg("Skipping stage")
+			continue
+		}
+
+		if err := ctx.Err(); err != nil {
+			return results, err
+		}
+
+		if chain := r.enricherChain(i, run); len(chain) > 1 {
+			chainResults, err := r.runChain(ctx, chain)
+			results = append(results, chainResults...)
+			if err != nil {
+				return results, err
+			}
+			i += len(chain) - 1
+			continue
+		}
+
+		result := &Result{Stage: stage.Name()}
+		results = append(results, result)
+
+		r.log.Info().Str("stage", string(stage.Name())).Msg("Running stage")
+
+		start := time.Now()
+		err := stage.Run(ctx, result)
+		result.Duration = time.Since(start)
+		if err != nil {
+			return results, err
+		}
+
+		r.logResult(result)
+	}
+
+	return results, nil
+}
+
+// enricherChain returns the selected enrichers starting at index i where each one
+// reads the single output of the previous one
+func (r *Runner) enricherChain(i int, run map[domain.Stage]bool) []Enricher {
+	chain := []Enricher{}
+	for ; i < len(r.stages); i++ {
+		enricher, ok := r.stages[i].(Enricher)
+		if !ok || !run[enricher.Name()] || len(enricher.Inputs()) != 1 || len(enricher.Outputs()) != 1 {
+			break
+		}
+		if len(chain) > 0 && enricher.Inputs()[0] != chain[len(chain)-1].Outputs()[0] {
+			break
+		}
+		chain = append(chain, enricher)
+	}
+
+	return chain
+}
+
+// runChain runs a chain of enrichers concurrently over the chain's input.
+// Each output is written with the fields of its enricher and all enrichers before it.
+func (r *Runner) runChain(ctx context.Context, chain []Enricher) (Results, error) {
+	names := make([]domain.Stage, 0, len(chain))
+	for _, enricher := range chain {
+		names = append(names, enricher.Name())
+	}
+	r.log.Info().Str("stages", joinStages(names)).Msg("Running stages concurrently")
+
+	input := chain[0].Inputs()[0]
+
+	// The first failing enricher cancels the others
+	chainCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	results := make(Results, len(chain))
+	fills := make([]func(*domain.Anime), len(chain))
+	errs := make([]error, len(chain))
+
+	var wg sync.WaitGroup
+	for i, enricher := range chain {
+		results[i] = &Result{Stage: enricher.Name()}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			start := time.Now()
+			fills[i], errs[i] = enricher.Enrich(chainCtx, r.animeRepo.Iter(chainCtx, input), results[i])
+			results[i].Duration = time.Since(start)
+			if errs[i] != nil {
+				cancel()
+			}
+		}()
+	}
+	wg.Wait()
+
+	// Report the error that caused the cancellation rather than the cancellation itself
+	for i, err := range errs {
+		if err != nil && !errors.Is(err, context.Canceled) {
+			return results, fmt.Errorf("stage %s: %w", chain[i].Name(), err)
+		}
+	}
+	for _, err := range errs {
+		if err != nil {
+			return results, err
+		}
+	}
+
+	for i, enricher := range chain {
+		if err := store(ctx, r.animeRepo, input, enricher.Outputs()[0], fills[:i+1], results[i]); err != nil {
+			return results, err
+		}
+
+		r.logResult(results[i])
+	}
+
+	return results, nil
+}
+
+// RunEnricher runs an enricher on its own: it streams the enricher's input to enrich it and
+// stores the output while streaming the input again
+func RunEnricher(ctx context.Context, animeRepo domain.AnimeRepository, enricher Enricher, result *Result) error {
+	input := enricher.Inputs()[0]
+
+	fill, err := enricher.Enrich(ctx, animeRepo.Iter(ctx, input), result)
+	if err != nil {
+		return err
+	}
+
+	return store(ctx, animeRepo, input, enricher.Outputs()[0], []func(*domain.Anime){fill}, result)
+}
+
+// store streams input to output with the fields of fills filled in, counting the entries in the total counter
+func store(ctx context.Context, animeRepo domain.AnimeRepository, input, output domain.AnimePath, fills []func(*domain.Anime), result *Result) error {
+	total := 0
+	seq := func(yield func(domain.Anime, error) bool) {
+		for anime, err := range animeRepo.Iter(ctx, input) {
+			if err == nil {
+				for _, fill := range fills {
+					fill(&anime)
+				}
+				total++
+			}
+			if !yield(anime, err) || err != nil {
+				return
+			}
+		}
+	}
+
+	if err := animeRepo.StoreIter(ctx, output, seq); err != nil {
+		return fmt.Errorf("failed to store %s: %w", output, err)
+	}
+
+	result.Set("total", total)
+	return nil
+}
+
+// logResult logs the duration and counters of a completed stage
+func (r *Runner) logResult(result *Result) {
+	event := r.log.Info().Str("stage", string(result.Stage)).Dur("duration", result.Duration)
+
+	names := make([]string, 0, len(result.Counters))
+	for name := range result.Counters {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		event = event.Int(name, result.Counters[name])
+	}
+
+	event.Msg("Stage complete")
+}
+
+// checkInputs verifies that every input of the selected stages is either
+// produced by an earlier selected stage or already stored
+func (r *Runner) checkInputs(ctx context.Context, run map[domain.Stage]bool) error {
+	produced := make(map[domain.AnimePath]bool)
+	for _, stage := range r.stages {
+		if !run[stage.Name()] {
+			continue
+		}
+
+		for _, input := range stage.Inputs() {
+			if produced[input] {
+				continue
+			}
+			exists, err := r.animeRepo.Exists(ctx, input)
+			if err != nil {
+				return fmt.Errorf("stage %s needs %s from a previous run: %w", stage.Name(), input, err)
+			}
+			if !exists {
+				return fmt.Errorf("stage %s needs %s from a previous run: %s does not exist", stage.Name(), input, input)
+			}
+		}
+
+		for _, output := range stage.Outputs() {
+			produced[output] = true
+		}
+	}
+
+	return nil
+}
+
+// order sorts stages topologically by their inputs and outputs
+func order(stages []Stage) ([]Stage, error) {
+	producers := make(map[domain.AnimePath]int)
+	names := make(map[domain.Stage]bool)
+	for i, stage := range stages {
+		if names[stage.Name()] {
+			return nil, fmt.Errorf("duplicate stage: %s", stage.Name())
+		}
+		names[stage.Name()] = true
+
+		for _, output := range stage.Outputs() {
+			if j, ok := producers[output]; ok {
+				return nil, fmt.Errorf("stages %s and %s both write %s", stages[j].Name(), stage.Name(), output)
+			}
+			producers[output] = i
+		}
+	}
+
+	// Count dependencies on other stages, inputs without a producer are external files
+	pending := make([]int, len(stages))
+	dependents := make([][]int, len(stages))
+	for i, stage := range stages {
+		deps := make(map[int]bool)
+		for _, input := range stage.Inputs() {
+			if j, ok := producers[input]; ok && j != i && !deps[j] {
+				deps[j] = true
+				dependents[j] = append(dependents[j], i)
+			}
+		}
+		pending[i] = len(deps)
+	}
+
+	ready := []int{}
+	for i := range stages {
+		if pending[i] == 0 {
+			ready = append(ready, i)
+		}
+	}
+
+	ordered := make([]Stage, 0, len(stages))
+	for len(ready) > 0 {
+		// Lowest registration index first keeps the order stable
+		sort.Ints(ready)
+		i := ready[0]
+		ready = ready[1:]
+		ordered = append(ordered, stages[i])
+
+		for _, j := range dependents[i] {
+			pending[j]--
+			if pending[j] == 0 {
+				ready = append(ready, j)
+			}
+		}
+	}
+
+	if len(ordered) != len(stages) {
+		return nil, fmt.Errorf("pipeline stages have a dependency cycle")
+	}
+
+	return ordered, nil
+}
+
+func joinStages(stages []domain.Stage) string {
+	names := make([]string, 0, len(stages))
+	for _, stage := range stages {
+		names = append(names, "'"+string(stage)+"'")
+	}
+
+	return strings.Join(names, ", ")
+}
@@ -0,0 +1,156 @@
+package pipeline
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/varoOP/shinkrodb/internal/domain"
+)
+
+// Planner is a stage that can report what it would fetch without making requests or writing files
+type Planner interface {
+	Plan(ctx context.Context) (*domain.FetchPlan, error)
+}
+
+// OutputChange describes how a run would affect an output file
+type OutputChange string
+
+const (
+	// OutputNew - The file does not exist yet
+	OutputNew OutputChange = "new"
+	// OutputChanged - The file changes even if the fetches find nothing new
+	OutputChanged OutputChange = "changed"
+	// OutputMayChange - The file changes if the fetches or an earlier stage find something new
+	OutputMayChange OutputChange = "may change"
+	// OutputUnchanged - The file would be written with the same content
+	OutputUnchanged OutputChange = "unchanged"
+)
+
+// OutputPlan describes how a run would affect a single output file
+type OutputPlan struct {
+	Path   domain.AnimePath
+	Change OutputChange
+}
+
+// StagePlan is the dry run report of a single stage
+type StagePlan struct {
+	Stage   domain.Stage
+	Fetch   *domain.FetchPlan // nil if the stage can not be planned
+	Outputs []OutputPlan
+	Note    string
+}
+
+// Plan reports what the selected stages would fetch and which output files would change.
+// Nothing is requested or written; stages are planned from the files of the previous run.
+func (r *Runner) Plan(ctx context.Context, selected []domain.Stage) ([]*StagePlan, error) {
+	run := make(map[domain.Stage]bool, len(selected))
+	for _, name := range selected {
+		run[name] = true
+	}
+
+	if err := r.checkInputs(ctx, run); err != nil {
+		return nil, err
+	}
+
+	// Outputs that may change during this run
+	changing := make(map[domain.AnimePath]bool)
+
+	plans := []*StagePlan{}
+	for _, stage := range r.stages {
+		if !run[stage.Name()] {
+			continue
+		}
+
+		plan := &StagePlan{Stage: stage.Name()}
+		plans = append(plans, plan)
+
+		upstream := false
+		missing := false
+		for _, input := range stage.Inputs() {
+			if changing[input] {
+				upstream = true
+			}
+			exists, err := r.animeRepo.Exists(ctx, input)
+			if err != nil {
+				return nil, fmt.Errorf("failed to check %s: %w", input, err)
+			}
+			if !exists {
+				missing = true
+			}
+		}
+
+		if missing {
+			// The input is produced by an earlier selected stage, which does not write it in a dry run
+			plan.Note = "input is not available before the earlier stages run"
+		} else if planner, ok := stage.(Planner); ok {
+			fetch, err := planner.Plan(ctx)
+			if err != nil {
+				return nil, fmt.Errorf("failed to plan stage %s: %w", stage.Name(), err)
+			}
+			plan.Fetch = fetch
+		}
+
+		for _, output := range stage.Outputs() {
+			change, err := r.outputChange(ctx, output, plan.Fetch, upstream, len(stage.Outputs()) == 1)
+			if err != nil {
+				return nil, err
+			}
+			if change != OutputUnchanged {
+				changing[output] = true
+			}
+			plan.Outputs = append(plan.Outputs, OutputPlan{Path: output, Change: change})
+		}
+	}
+
+	return plans, nil
+}
+
+// outputChange decides how an output file would be affected by a stage
+func (r *Runner) outputChange(ctx context.Context, output domain.AnimePath, fetch *domain.FetchPlan, upstream, single bool) (OutputChange, error) {
+	exists, err := r.animeRepo.Exists(ctx, output)
+	if err != nil {
+		return "", fmt.Errorf("failed to check %s: %w", output, err)
+	}
+	if !exists {
+		return OutputNew, nil
+	}
+
+	// The expected output can only be compared for stages with a single output
+	if fetch != nil && fetch.Output != nil && single {
+		current, err := r.animeRepo.Get(ctx, output)
+		if err != nil {
+			return "", fmt.Errorf("failed to read %s: %w", output, err)
+		}
+
+		equal, err := sameAnime(current, fetch.Output)
+		if err != nil {
+			return "", err
+		}
+		if !equal {
+			return OutputChanged, nil
+		}
+	}
+
+	if fetch == nil || fetch.Output == nil || upstream || len(fetch.ToFetch) > 0 {
+		return OutputMayChange, nil
+	}
+
+	return OutputUnchanged, nil
+}
+
+// sameAnime reports whether two anime lists serialize to the same JSON
+func sameAnime(a, b []domain.Anime) (bool, error) {
+	ja, err := json.Marshal(a)
+	if err != nil {
+		return false, fmt.Errorf("failed to marshal anime data: %w", err)
+	}
+
+	jb, err := json.Marshal(b)
+	if err != nil {
+		return false, fmt.Errorf("failed to marshal anime data: %w", err)
+	}
+
+	return bytes.Equal(ja, jb), nil
+}
@@ -0,0 +1,165 @@
+package publish
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog"
+	"github.com/varoOP/shinkrodb/internal/domain"
+)
+
+type Service interface {
+	// Previous returns the currently published release, nil if there is none
+	Previous(ctx context.Context) ([]byte, error)
+	// Publish writes the compressed variants of the new release and its delta against previous
+	Publish(ctx context.Context, previous []byte) error
+}
+
+type service struct {
+	log         zerolog.Logger
+	releaseRepo domain.ReleaseRepository
+	path        string
+}
+
+// NewService creates a publish service for the output file at path
+func NewService(log zerolog.Logger, releaseRepo domain.ReleaseRepository, path string) Service {
+	return &service{
+		log:         log.With().Str("module", "publish").Logger(),
+		releaseRepo: releaseRepo,
+		path:        path,
+	}
+}
+
+func (s *service) Previous(ctx context.Context) ([]byte, error) {
+	previous, err := s.releaseRepo.GetRelease(ctx, s.path)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read previous release")
+	}
+
+	return previous, nil
+}
+
+// Publish writes the gzip and zstd variants of the release at the service path and, if there is a previous
+// release, the delta against it. An unchanged release keeps the existing delta, so clients still on the
+// release before can keep updating cheaply.
+func (s *service) Publish(ctx context.Context, previous []byte) error {
+	current, err := s.releaseRepo.GetRelease(ctx, s.path)
+	if err != nil {
+		return errors.Wrap(err, "failed to read release")
+	}
+	if current == nil {
+		return fmt.Errorf("release %s does not exist", s.path)
+	}
+
+	for _, compression := range domain.Compressions {
+		if err := s.releaseRepo.StoreCompressed(ctx, s.path, current, compression); err != nil {
+			return errors.Wrapf(err, "failed to store %s release", compression)
+		}
+	}
+
+	if previous == nil {
+		s.log.Info().Msg("No previous release, skipping delta")
+		return nil
+	}
+
+	if bytes.Equal(previous, current) {
+		s.log.Info().Msg("Release unchanged, keeping the previous delta")
+		return nil
+	}
+
+	delta, err := diff(previous, current)
+	if err != nil {
+		return errors.Wrap(err, "failed to compute delta")
+	}
+
+	if err := s.releaseRepo.StoreDelta(ctx, domain.DeltaPath(s.path), delta); err != nil {
+		return errors.Wrap(err, "failed to store delta")
+	}
+
+	s.log.Info().Int("operations", len(delta.Patch)).Msg("Release published")
+	return nil
+}
+
+// diff computes the patch from the previous to the current release, ordered by MAL ID
+func diff(previous, current []byte) (*domain.ReleaseDelta, error) {
+	before, err := decodeRelease(previous)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to decode previous release")
+	}
+
+	after, err := decodeRelease(current)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to decode release")
+	}
+
+	delta := &domain.ReleaseDelta{
+		BaseSHA256: checksum(previous),
+		SHA256:     checksum(current),
+		Patch:      []domain.PatchOperation{},
+	}
+
+	malIDs := make([]int, 0, len(after))
+	for malID := range after {
+		malIDs = append(malIDs, malID)
+	}
+	for malID := range before {
+		if _, ok := after[malID]; !ok {
+			malIDs = append(malIDs, malID)
+		}
+	}
+	sort.Ints(malIDs)
+
+	for _, malID := range malIDs {
+		path := fmt.Sprintf("/%d", malID)
+		old, hadOld := before[malID]
+		anime, hasNew := after[malID]
+
+		switch {
+		case !hasNew:
+			delta.Patch = append(delta.Patch, domain.PatchOperation{Op: domain.PatchOpRemove, Path: path})
+		case !hadOld:
+			delta.Patch = append(delta.Patch, domain.PatchOperation{Op: domain.PatchOpAdd, Path: path, Value: &anime.Anime})
+		case !bytes.Equal(old.raw, anime.raw):
+			delta.Patch = append(delta.Patch, domain.PatchOperation{Op: domain.PatchOpReplace, Path: path, Value: &anime.Anime})
+		}
+	}
+
+	return delta, nil
+}
+
+// releaseEntry is a decoded entry of a release with its normalized JSON encoding
+type releaseEntry struct {
+	domain.Anime
+	raw []byte
+}
+
+// decodeRelease decodes a release into its entries keyed by MAL ID
+func decodeRelease(data []byte) (map[int]releaseEntry, error) {
+	var anime []domain.Anime
+	if err := json.Unmarshal(data, &anime); err != nil {
+		return nil, err
+	}
+
+	entries := make(map[int]releaseEntry, len(anime))
+	for _, a := range anime {
+		raw, err := json.Marshal(a)
+		if err != nil {
+			return nil, err
+		}
+		entries[a.MalID] = releaseEntry{Anime: a, raw: raw}
+	}
+
+	return entries, nil
+}
+
+// checksum returns the hex encoded SHA-256 of data
+func checksum(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
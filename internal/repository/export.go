@@ -0,0 +1,191 @@
+package repository
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+
+	"github.com/varoOP/shinkrodb/internal/domain"
+	_ "modernc.org/sqlite"
+)
+
+// exportSchema is the schema of SQLite exports
+const exportSchema = `
+CREATE TABLE anime (
+	mal_id       INTEGER PRIMARY KEY,
+	title        TEXT NOT NULL,
+	en_title     TEXT,
+	anidb_id     INTEGER,
+	tvdb_id      INTEGER,
+	tmdb_id      INTEGER,
+	type         TEXT NOT NULL,
+	release_date TEXT
+);
+CREATE INDEX anime_anidb_id_index ON anime (anidb_id);
+CREATE INDEX anime_tvdb_id_index ON anime (tvdb_id);
+CREATE INDEX anime_tmdb_id_index ON anime (tmdb_id);
+`
+
+// csvHeader is the header row of CSV exports, the columns match the JSON field names
+var csvHeader = []string{"malid", "title", "enTitle", "anidbid", "tvdbid", "tmdbid", "type", "releaseDate"}
+
+// export writes anime to path in format
+func (r *FileRepository) export(ctx context.Context, path string, format domain.OutputFormat, anime []domain.Anime) error {
+	// Exports are sorted by MAL ID so they diff cleanly between runs
+	sorted := slices.Clone(anime)
+	slices.SortStableFunc(sorted, func(a, b domain.Anime) int { return a.MalID - b.MalID })
+
+	switch format {
+	case domain.OutputFormatNDJSON:
+		return exportNDJSON(ctx, path, sorted)
+	case domain.OutputFormatCSV:
+		return exportCSV(ctx, path, sorted)
+	case domain.OutputFormatSQLite:
+		return exportSQLite(ctx, path, sorted)
+	default:
+		return fmt.Errorf("unsupported output format: %s", format)
+	}
+}
+
+// exportNDJSON writes one JSON object per line
+func exportNDJSON(ctx context.Context, path string, anime []domain.Anime) error {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for _, a := range anime {
+		if err := enc.Encode(a); err != nil {
+			return fmt.Errorf("failed to marshal anime %d: %w", a.MalID, err)
+		}
+	}
+
+	return writeFileAtomic(ctx, path, buf.Bytes())
+}
+
+// exportCSV writes a header row and one row per anime, unset IDs are left empty
+func exportCSV(ctx context.Context, path string, anime []domain.Anime) error {
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	if err := w.Write(csvHeader); err != nil {
+		return fmt.Errorf("failed to write csv header: %w", err)
+	}
+
+	for _, a := range anime {
+		record := []string{
+			strconv.Itoa(a.MalID),
+			a.MainTitle,
+			a.EnglishTitle,
+			formatID(a.AnidbID),
+			formatID(a.TvdbID),
+			formatID(a.TmdbID),
+			a.Type,
+			a.ReleaseDate,
+		}
+		if err := w.Write(record); err != nil {
+			return fmt.Errorf("failed to write csv row for %d: %w", a.MalID, err)
+		}
+	}
+
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return fmt.Errorf("failed to write csv: %w", err)
+	}
+
+	return writeFileAtomic(ctx, path, buf.Bytes())
+}
+
+// formatID formats an external ID for CSV, 0 (unset) becomes an empty field
+func formatID(id int) string {
+	if id == 0 {
+		return ""
+	}
+	return strconv.Itoa(id)
+}
+
+// nullID converts an external ID for SQLite, 0 (unset) becomes NULL
+func nullID(id int) sql.NullInt64 {
+	return sql.NullInt64{Int64: int64(id), Valid: id != 0}
+}
+
+// exportSQLite builds a standalone SQLite database in a temporary file and moves it into place read-only (0444).
+// Like writeFileAtomic, an existing export is only replaced by a complete database.
+func exportSQLite(ctx context.Context, path string, anime []domain.Anime) (err error) {
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create directory %s: %w", dir, err)
+	}
+
+	f, err := os.CreateTemp(dir, "."+filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary file for %s: %w", path, err)
+	}
+	tmpPath := f.Name()
+	f.Close()
+
+	defer func() {
+		if err != nil {
+			os.Remove(tmpPath)
+		}
+	}()
+
+	if err := buildSQLiteExport(ctx, tmpPath, anime); err != nil {
+		return fmt.Errorf("failed to build %s: %w", path, err)
+	}
+
+	if err := os.Chmod(tmpPath, 0444); err != nil {
+		return fmt.Errorf("failed to set permissions on file %s: %w", path, err)
+	}
+
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("discarded %s: %w", path, err)
+	}
+
+	if err := os.Rename(tmpPath, path); err != nil {
+		return fmt.Errorf("failed to move file into place %s: %w", path, err)
+	}
+
+	return nil
+}
+
+// buildSQLiteExport creates the export schema at path and inserts anime in a single transaction
+func buildSQLiteExport(ctx context.Context, path string, anime []domain.Anime) error {
+	// The rollback journal is deleted on commit, so the export is a single file
+	db, err := sql.Open("sqlite", path+"?_pragma=journal_mode(DELETE)")
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	if _, err := db.ExecContext(ctx, exportSchema); err != nil {
+		return fmt.Errorf("failed to create schema: %w", err)
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.PrepareContext(ctx, "INSERT INTO anime (mal_id, title, en_title, anidb_id, tvdb_id, tmdb_id, type, release_date) VALUES ($1, $2, $3, $4, $5, $6, $7, $8)")
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	for _, a := range anime {
+		if _, err := stmt.ExecContext(ctx, a.MalID, a.MainTitle, a.EnglishTitle, nullID(a.AnidbID), nullID(a.TvdbID), nullID(a.TmdbID), a.Type, a.ReleaseDate); err != nil {
+			return fmt.Errorf("failed to insert anime %d: %w", a.MalID, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	return db.Close()
+}
@@ -1,10 +1,12 @@
 package repository
 
 import (
+	"bufio"
 	"context"
 	"encoding/json"
 	"fmt"
 	"io"
+	"iter"
 	"os"
 	"path/filepath"
 	"strings"
@@ -16,80 +18,160 @@ import (
 
 // FileRepository implements domain.AnimeRepository and domain.MappingRepository using file storage
 type FileRepository struct {
-	log zerolog.Logger
+	log     zerolog.Logger
+	formats map[domain.AnimeFile][]domain.OutputFormat
 }
 
-// NewFileRepository creates a new file-based repository
-func NewFileRepository(log zerolog.Logger) *FileRepository {
+// NewFileRepository creates a new file-based repository.
+// Anime lists stored to one of the files in formats are also exported to the listed formats.
+func NewFileRepository(log zerolog.Logger, formats map[domain.AnimeFile][]domain.OutputFormat) *FileRepository {
 	return &FileRepository{
-		log: log.With().Str("module", "repository").Logger(),
+		log:     log.With().Str("module", "repository").Logger(),
+		formats: formats,
 	}
 }
 
 // Ensure FileRepository implements both interfaces
 var _ domain.AnimeRepository = (*FileRepository)(nil)
 var _ domain.MappingRepository = (*FileRepository)(nil)
+var _ domain.IndexRepository = (*FileRepository)(nil)
+var _ domain.ManifestRepository = (*FileRepository)(nil)
+var _ domain.DedupeReportRepository = (*FileRepository)(nil)
 
 // Get retrieves anime data from a file
 func (r *FileRepository) Get(ctx context.Context, path domain.AnimePath) ([]domain.Anime, error) {
 	a := []domain.Anime{}
+	for anime, err := range r.Iter(ctx, path) {
+		if err != nil {
+			return nil, err
+		}
+		a = append(a, anime)
+	}
 
-	// Check if path exists and is a file (not a directory)
+	return a, nil
+}
+
+// Exists reports whether the anime file exists
+func (r *FileRepository) Exists(ctx context.Context, path domain.AnimePath) (bool, error) {
 	info, err := os.Stat(string(path))
 	if err != nil {
 		if os.IsNotExist(err) {
-			return nil, fmt.Errorf("file does not exist: %s: %w", path, err)
+			return false, nil
 		}
-		return nil, fmt.Errorf("failed to stat file %s: %w", path, err)
-	}
-	if info.IsDir() {
-		return nil, fmt.Errorf("path is a directory, not a file: %s", path)
+		return false, fmt.Errorf("failed to stat file %s: %w", path, err)
 	}
 
-	f, err := os.Open(string(path))
+	return !info.IsDir(), nil
+}
+
+// Store saves anime data to a file
+func (r *FileRepository) Store(ctx context.Context, path domain.AnimePath, anime []domain.Anime) error {
+	count, err := r.writeAnime(ctx, path, func(yield func(domain.Anime, error) bool) {
+		for _, a := range anime {
+			if !yield(a, nil) {
+				return
+			}
+		}
+	})
 	if err != nil {
-		return nil, fmt.Errorf("failed to open file %s: %w", path, err)
+		return err
 	}
-	defer f.Close()
 
-	body, err := io.ReadAll(f)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read file %s: %w", path, err)
+	r.log.Debug().Str("path", string(path)).Int("count", count).Msg("stored anime data")
+
+	return r.exportAll(ctx, path, anime)
+}
+
+// StoreIter saves anime data streamed from seq to a file.
+// If exports are configured for the file, the entries are also collected for them.
+func (r *FileRepository) StoreIter(ctx context.Context, path domain.AnimePath, seq iter.Seq2[domain.Anime, error]) error {
+	var collected []domain.Anime
+	if len(r.formats[domain.AnimeFile(filepath.Base(string(path)))]) > 0 {
+		source := seq
+		seq = func(yield func(domain.Anime, error) bool) {
+			for a, err := range source {
+				if err == nil {
+					collected = append(collected, a)
+				}
+				if !yield(a, err) {
+					return
+				}
+			}
+		}
 	}
 
-	err = json.Unmarshal(body, &a)
+	count, err := r.writeAnime(ctx, path, seq)
 	if err != nil {
-		return nil, fmt.Errorf("failed to unmarshal json from %s: %w", path, err)
+		return err
 	}
 
-	return a, nil
+	r.log.Debug().Str("path", string(path)).Int("count", count).Msg("stored anime data")
+
+	return r.exportAll(ctx, path, collected)
 }
 
-// Store saves anime data to a file
-func (r *FileRepository) Store(ctx context.Context, path domain.AnimePath, anime []domain.Anime) error {
-	j, err := json.MarshalIndent(anime, "", "   ")
+// exportAll exports anime to the formats configured for path.
+// JSON stays the primary format since stages read it, the other formats are exported next to it.
+func (r *FileRepository) exportAll(ctx context.Context, path domain.AnimePath, anime []domain.Anime) error {
+	for _, format := range r.formats[domain.AnimeFile(filepath.Base(string(path)))] {
+		exportPath := domain.ExportPath(string(path), format)
+		if err := r.export(ctx, exportPath, format, anime); err != nil {
+			return fmt.Errorf("failed to export %s: %w", exportPath, err)
+		}
+		r.log.Debug().Str("path", exportPath).Str("format", string(format)).Msg("exported anime data")
+	}
+
+	return nil
+}
+
+// StoreIndex saves a reverse lookup index to a file
+func (r *FileRepository) StoreIndex(ctx context.Context, path string, index domain.AnimeIndex) error {
+	j, err := json.MarshalIndent(index, "", "   ")
 	if err != nil {
-		return fmt.Errorf("failed to marshal anime data: %w", err)
+		return fmt.Errorf("failed to marshal index: %w", err)
 	}
 
-	// Ensure directory exists
-	dir := filepath.Dir(string(path))
-	if err := os.MkdirAll(dir, 0755); err != nil {
-		return fmt.Errorf("failed to create directory %s: %w", dir, err)
+	if err := writeFileAtomic(ctx, path, j); err != nil {
+		return err
 	}
 
-	f, err := os.Create(string(path))
+	r.log.Debug().Str("path", path).Int("count", len(index)).Msg("stored index")
+	return nil
+}
+
+// StoreManifest saves the output manifest to a file
+func (r *FileRepository) StoreManifest(ctx context.Context, path string, manifest *domain.Manifest) error {
+	j, err := json.MarshalIndent(manifest, "", "   ")
 	if err != nil {
-		return fmt.Errorf("failed to create file %s: %w", path, err)
+		return fmt.Errorf("failed to marshal manifest: %w", err)
 	}
-	defer f.Close()
 
-	_, err = f.Write(j)
+	if err := writeFileAtomic(ctx, path, j); err != nil {
+		return err
+	}
+
+	r.log.Debug().Str("path", path).Int("files", len(manifest.Files)).Msg("stored manifest")
+	return nil
+}
+
+// StoreDedupeReport saves the dedupe report to a JSON or YAML file
+func (r *FileRepository) StoreDedupeReport(ctx context.Context, path string, report *domain.DedupeReport) error {
+	var data []byte
+	var err error
+	if strings.HasSuffix(path, ".yaml") {
+		data, err = yaml.Marshal(report)
+	} else {
+		data, err = json.MarshalIndent(report, "", "   ")
+	}
 	if err != nil {
-		return fmt.Errorf("failed to write to file %s: %w", path, err)
+		return fmt.Errorf("failed to marshal dedupe report: %w", err)
 	}
 
-	r.log.Debug().Str("path", string(path)).Int("count", len(anime)).Msg("stored anime data")
+	if err := writeFileAtomic(ctx, path, data); err != nil {
+		return err
+	}
+
+	r.log.Debug().Str("path", path).Int("groups", len(report.Groups)).Msg("stored dedupe report")
 	return nil
 }
 
@@ -122,17 +204,6 @@ func (r *FileRepository) StoreTMDBMaster(ctx context.Context, path string, movie
 		return fmt.Errorf("failed to marshal yaml: %w", err)
 	}
 
-	// Ensure directory exists
-	dir := filepath.Dir(path)
-	if err := os.MkdirAll(dir, 0755); err != nil {
-		return fmt.Errorf("failed to create directory %s: %w", dir, err)
-	}
-
-	f, err := os.Create(path)
-	if err != nil {
-		return fmt.Errorf("failed to create file: %w", err)
-	}
-
 	text := string(b)
 	lines := strings.Split(text, "\n")
 	for i, line := range lines {
@@ -142,10 +213,8 @@ func (r *FileRepository) StoreTMDBMaster(ctx context.Context, path string, movie
 	}
 
 	modifiedText := strings.Join(lines, "\n")
-	defer f.Close()
-	_, err = f.Write([]byte(modifiedText))
-	if err != nil {
-		return fmt.Errorf("failed to write file: %w", err)
+	if err := writeFileAtomic(ctx, path, []byte(modifiedText)); err != nil {
+		return err
 	}
 
 	r.log.Debug().Str("path", path).Msg("stored TMDB master")
@@ -181,17 +250,6 @@ func (r *FileRepository) StoreTVDBMaster(ctx context.Context, path string, map_
 		return fmt.Errorf("failed to marshal yaml: %w", err)
 	}
 
-	// Ensure directory exists
-	dir := filepath.Dir(path)
-	if err := os.MkdirAll(dir, 0755); err != nil {
-		return fmt.Errorf("failed to create directory %s: %w", dir, err)
-	}
-
-	f, err := os.Create(path)
-	if err != nil {
-		return fmt.Errorf("failed to create file: %w", err)
-	}
-
 	text := string(b)
 	lines := strings.Split(text, "\n")
 	malidFound := false
@@ -206,13 +264,73 @@ func (r *FileRepository) StoreTVDBMaster(ctx context.Context, path string, map_
 	}
 
 	modifiedText := strings.Join(lines, "\n")
-	defer f.Close()
-	_, err = f.Write([]byte(modifiedText))
-	if err != nil {
-		return fmt.Errorf("failed to write file: %w", err)
+	if err := writeFileAtomic(ctx, path, []byte(modifiedText)); err != nil {
+		return err
 	}
 
 	r.log.Debug().Str("path", path).Msg("stored TVDB master")
 	return nil
 }
 
+// writeFileAtomic writes data to a temporary file next to path and renames it into place,
+// so an interrupted run never leaves a partially written file behind.
+// If ctx is cancelled before the rename, the temporary file is discarded and path is left untouched.
+func writeFileAtomic(ctx context.Context, path string, data []byte) error {
+	return writeFileAtomicFunc(ctx, path, func(w io.Writer) error {
+		_, err := w.Write(data)
+		return err
+	})
+}
+
+// writeFileAtomicFunc is writeFileAtomic for content produced by write, e.g. while it is encoded
+func writeFileAtomicFunc(ctx context.Context, path string, write func(w io.Writer) error) (err error) {
+	// Ensure directory exists
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create directory %s: %w", dir, err)
+	}
+
+	f, err := os.CreateTemp(dir, "."+filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary file for %s: %w", path, err)
+	}
+	tmpPath := f.Name()
+
+	defer func() {
+		if err != nil {
+			f.Close()
+			os.Remove(tmpPath)
+		}
+	}()
+
+	w := bufio.NewWriter(f)
+	if err := write(w); err != nil {
+		return fmt.Errorf("failed to write to file %s: %w", path, err)
+	}
+
+	if err := w.Flush(); err != nil {
+		return fmt.Errorf("failed to write to file %s: %w", path, err)
+	}
+
+	if err := f.Sync(); err != nil {
+		return fmt.Errorf("failed to sync file %s: %w", path, err)
+	}
+
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("failed to close file %s: %w", path, err)
+	}
+
+	if err := os.Chmod(tmpPath, 0644); err != nil {
+		return fmt.Errorf("failed to set permissions on file %s: %w", path, err)
+	}
+
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("discarded %s: %w", path, err)
+	}
+
+	if err := os.Rename(tmpPath, path); err != nil {
+		return fmt.Errorf("failed to move file into place %s: %w", path, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,84 @@
+package repository
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/klauspost/compress/gzip"
+	"github.com/klauspost/compress/zstd"
+	"github.com/varoOP/shinkrodb/internal/domain"
+)
+
+var _ domain.ReleaseRepository = (*FileRepository)(nil)
+
+// GetRelease returns the raw content of a published file, nil if it does not exist
+func (r *FileRepository) GetRelease(ctx context.Context, path string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read file %s: %w", path, err)
+	}
+
+	return data, nil
+}
+
+// StoreCompressed compresses data and saves it to the compressed variant of path
+func (r *FileRepository) StoreCompressed(ctx context.Context, path string, data []byte, compression domain.Compression) error {
+	var buf bytes.Buffer
+	switch compression {
+	case domain.CompressionGzip:
+		w, err := gzip.NewWriterLevel(&buf, gzip.BestCompression)
+		if err != nil {
+			return fmt.Errorf("failed to create gzip writer: %w", err)
+		}
+		if _, err := w.Write(data); err != nil {
+			return fmt.Errorf("failed to gzip %s: %w", path, err)
+		}
+		if err := w.Close(); err != nil {
+			return fmt.Errorf("failed to gzip %s: %w", path, err)
+		}
+
+	case domain.CompressionZstd:
+		w, err := zstd.NewWriter(&buf, zstd.WithEncoderLevel(zstd.SpeedBestCompression))
+		if err != nil {
+			return fmt.Errorf("failed to create zstd writer: %w", err)
+		}
+		if _, err := w.Write(data); err != nil {
+			return fmt.Errorf("failed to zstd compress %s: %w", path, err)
+		}
+		if err := w.Close(); err != nil {
+			return fmt.Errorf("failed to zstd compress %s: %w", path, err)
+		}
+
+	default:
+		return fmt.Errorf("unsupported compression: %s", compression)
+	}
+
+	compressedPath := domain.CompressedPath(path, compression)
+	if err := writeFileAtomic(ctx, compressedPath, buf.Bytes()); err != nil {
+		return err
+	}
+
+	r.log.Debug().Str("path", compressedPath).Int("size", buf.Len()).Msg("stored compressed release")
+	return nil
+}
+
+// StoreDelta saves a release delta to a file
+func (r *FileRepository) StoreDelta(ctx context.Context, path string, delta *domain.ReleaseDelta) error {
+	j, err := json.MarshalIndent(delta, "", "   ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal delta: %w", err)
+	}
+
+	if err := writeFileAtomic(ctx, path, j); err != nil {
+		return err
+	}
+
+	r.log.Debug().Str("path", path).Int("operations", len(delta.Patch)).Msg("stored release delta")
+	return nil
+}
@@ -0,0 +1,137 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"iter"
+	"os"
+
+	"github.com/varoOP/shinkrodb/internal/domain"
+)
+
+// Iter streams anime data from a file, decoding one entry at a time.
+// The first error is yielded once and ends the sequence.
+func (r *FileRepository) Iter(ctx context.Context, path domain.AnimePath) iter.Seq2[domain.Anime, error] {
+	return func(yield func(domain.Anime, error) bool) {
+		// Check if path exists and is a file (not a directory)
+		info, err := os.Stat(string(path))
+		if err != nil {
+			if os.IsNotExist(err) {
+				yield(domain.Anime{}, fmt.Errorf("file does not exist: %s: %w", path, err))
+				return
+			}
+			yield(domain.Anime{}, fmt.Errorf("failed to stat file %s: %w", path, err))
+			return
+		}
+		if info.IsDir() {
+			yield(domain.Anime{}, fmt.Errorf("path is a directory, not a file: %s", path))
+			return
+		}
+
+		f, err := os.Open(string(path))
+		if err != nil {
+			yield(domain.Anime{}, fmt.Errorf("failed to open file %s: %w", path, err))
+			return
+		}
+		defer f.Close()
+
+		for a, err := range decodeAnime(ctx, f) {
+			if err != nil {
+				yield(domain.Anime{}, fmt.Errorf("failed to unmarshal json from %s: %w", path, err))
+				return
+			}
+			if !yield(a, nil) {
+				return
+			}
+		}
+	}
+}
+
+// decodeAnime decodes a JSON array of anime token by token, so only one entry is held in memory.
+// A JSON null is an empty array, like json.Unmarshal into a slice.
+func decodeAnime(ctx context.Context, rd io.Reader) iter.Seq2[domain.Anime, error] {
+	return func(yield func(domain.Anime, error) bool) {
+		dec := json.NewDecoder(rd)
+
+		tok, err := dec.Token()
+		if err != nil {
+			yield(domain.Anime{}, err)
+			return
+		}
+		if tok == nil {
+			return
+		}
+		if delim, ok := tok.(json.Delim); !ok || delim != '[' {
+			yield(domain.Anime{}, fmt.Errorf("expected array, got %v", tok))
+			return
+		}
+
+		for dec.More() {
+			if err := ctx.Err(); err != nil {
+				yield(domain.Anime{}, err)
+				return
+			}
+
+			var a domain.Anime
+			if err := dec.Decode(&a); err != nil {
+				yield(domain.Anime{}, err)
+				return
+			}
+			if !yield(a, nil) {
+				return
+			}
+		}
+
+		if _, err := dec.Token(); err != nil {
+			yield(domain.Anime{}, err)
+		}
+	}
+}
+
+// writeAnime atomically writes the anime in seq to path as an indented JSON array, encoding one entry at a time.
+// The output is identical to json.MarshalIndent(anime, "", "   ") of the collected entries.
+func (r *FileRepository) writeAnime(ctx context.Context, path domain.AnimePath, seq iter.Seq2[domain.Anime, error]) (int, error) {
+	count := 0
+	err := writeFileAtomicFunc(ctx, string(path), func(w io.Writer) error {
+		if _, err := io.WriteString(w, "["); err != nil {
+			return err
+		}
+
+		for a, err := range seq {
+			if err != nil {
+				return err
+			}
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+
+			j, err := json.MarshalIndent(a, "   ", "   ")
+			if err != nil {
+				return fmt.Errorf("failed to marshal anime %d: %w", a.MalID, err)
+			}
+
+			sep := ",\n   "
+			if count == 0 {
+				sep = "\n   "
+			}
+			if _, err := io.WriteString(w, sep); err != nil {
+				return err
+			}
+			if _, err := w.Write(j); err != nil {
+				return err
+			}
+			count++
+		}
+
+		end := "]"
+		if count > 0 {
+			end = "\n]"
+		}
+		_, err := io.WriteString(w, end)
+		return err
+	})
+
+	return count, err
+}
@@ -5,20 +5,27 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"iter"
 	"log"
 	"net/http"
 	"net/url"
 	"path/filepath"
 	"regexp"
+	"strconv"
+	"sync"
+	"time"
 
 	"github.com/pkg/errors"
 	"github.com/rs/zerolog"
 	"github.com/varoOP/shinkrodb/internal/domain"
 	"github.com/varoOP/shinkrodb/pkg/animelist"
+	"golang.org/x/time/rate"
 )
 
 type Service interface {
-	GetTmdbIds(ctx context.Context, rootPath string, cacheRepo domain.CacheRepo) error
+	EnrichTmdbIDs(ctx context.Context, rootPath string, anime iter.Seq2[domain.Anime, error], cacheRepo domain.CacheRepo) (map[int]int, error)
+	PlanTmdbIDs(ctx context.Context, cacheRepo domain.CacheRepo) (*domain.FetchPlan, error)
+	RefreshTmdbID(ctx context.Context, cacheRepo domain.CacheRepo, anime domain.Anime) (int, domain.Provenance, error)
 }
 
 type service struct {
@@ -26,9 +33,14 @@ type service struct {
 	config      *domain.Config
 	animeRepo   domain.AnimeRepository
 	mappingRepo domain.MappingRepository
-	paths       *domain.Paths
+	inputPath   domain.AnimePath
+	client      *http.Client
+	limiter     *rate.Limiter
 }
 
+// maxRateLimitRetries is how often a search is retried after TMDB answers 429 Too Many Requests
+const maxRateLimitRetries = 3
+
 type TMDBAPIResponse struct {
 	Page    int `json:"page"`
 	Results []struct {
@@ -51,182 +63,224 @@ type TMDBAPIResponse struct {
 	TotalResults int `json:"total_results"`
 }
 
-func NewService(log zerolog.Logger, config *domain.Config, animeRepo domain.AnimeRepository, mappingRepo domain.MappingRepository, paths *domain.Paths) Service {
+// NewService creates a TMDB service that looks up TMDB IDs; fetch plans are built from the anime in inputPath
+func NewService(log zerolog.Logger, config *domain.Config, animeRepo domain.AnimeRepository, mappingRepo domain.MappingRepository, inputPath domain.AnimePath) Service {
 	return &service{
 		log:         log.With().Str("module", "tmdb").Logger(),
 		config:      config,
 		animeRepo:   animeRepo,
 		mappingRepo: mappingRepo,
-		paths:       paths,
+		inputPath:   inputPath,
+		client:      newClient(config),
+		// Token bucket shared by all lookup workers, allows a burst of one second worth of requests
+		limiter: rate.NewLimiter(rate.Limit(config.TMDBRateLimit), max(int(config.TMDBRateLimit), 1)),
 	}
 }
 
-func (s *service) GetTmdbIds(ctx context.Context, rootPath string, cacheRepo domain.CacheRepo) error {
-	a, err := s.animeRepo.Get(ctx, s.paths.TVDBPath)
-	if err != nil {
-		return errors.Wrap(err, "failed to get anime list")
+// newClient creates the HTTP client shared by all TMDB lookups
+func newClient(config *domain.Config) *http.Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.MaxIdleConnsPerHost = config.TMDBConcurrency
+	transport.ResponseHeaderTimeout = config.TMDBTimeout
+
+	return &http.Client{
+		Transport: transport,
+		Timeout:   config.TMDBTimeout,
 	}
+}
 
-	// Get cached TMDB IDs
-	cachedTmdbIDs := make(map[int]int)
+// cachedTmdbIDs returns the cached TMDB IDs by MAL ID, none if the cache cannot be read
+func (s *service) cachedTmdbIDs(ctx context.Context, cacheRepo domain.CacheRepo) map[int]int {
 	if cacheRepo != nil {
-		tmdbMap, err := cacheRepo.GetTMDBIDs(ctx)
-		if err == nil {
-			cachedTmdbIDs = tmdbMap
-			// Update anime list with cached TMDB IDs
-			for i := range a {
-				if tmdbID, found := cachedTmdbIDs[a[i].MalID]; found && tmdbID > 0 {
-					a[i].TmdbID = tmdbID
-				}
-			}
+		if tmdbMap, err := cacheRepo.GetTMDBIDs(ctx); err == nil {
+			return tmdbMap
+		}
+	}
+
+	return make(map[int]int)
+}
+
+// prepareFetch applies cached TMDB IDs to a and returns the movies to look up
+func (s *service) prepareFetch(ctx context.Context, a []domain.Anime, cacheRepo domain.CacheRepo) []domain.Anime {
+	cachedTmdbIDs := s.cachedTmdbIDs(ctx, cacheRepo)
+
+	// Update anime list with cached TMDB IDs
+	for i := range a {
+		if tmdbID, found := cachedTmdbIDs[a[i].MalID]; found && tmdbID > 0 {
+			a[i].TmdbID = tmdbID
+		}
+	}
+
+	// Filter movies to fetch based on configured TMDB mode
+	return s.filterMoviesToFetch(a, cachedTmdbIDs)
+}
+
+// avgSearchResponseTime is a rough average response time of a TMDB search request, used for estimates
+const avgSearchResponseTime = 300 * time.Millisecond
+
+// PlanTmdbIDs reports which movies EnrichTmdbIDs would look up, without making any requests
+func (s *service) PlanTmdbIDs(ctx context.Context, cacheRepo domain.CacheRepo) (*domain.FetchPlan, error) {
+	a, err := s.animeRepo.Get(ctx, s.inputPath)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get anime list")
+	}
+
+	toFetch := s.prepareFetch(ctx, a, cacheRepo)
+
+	plan := &domain.FetchPlan{
+		ToFetch: toFetch,
+		Output:  a,
+		Note:    "upper bound, movies found in anime-list.xml need no search",
+	}
+
+	for _, anime := range a {
+		if anime.Type == "movie" {
+			plan.Candidates++
+		}
+	}
+
+	// Movies without a release date are never searched
+	for _, anime := range toFetch {
+		if anime.ReleaseDate != "" {
+			plan.Requests++
+		}
+	}
+
+	// Bound by either the rate limit or the worker pool, whichever is slower
+	byRate := time.Duration(float64(plan.Requests) / s.config.TMDBRateLimit * float64(time.Second))
+	byWorkers := time.Duration(plan.Requests) * avgSearchResponseTime / time.Duration(s.config.TMDBConcurrency)
+	plan.Duration = max(byRate, byWorkers)
+
+	return plan, nil
+}
+
+// movieLookup is the outcome of the TMDB ID lookup of a single movie
+type movieLookup struct {
+	anime      domain.Anime
+	tmdbID     int
+	provenance domain.Provenance
+}
+
+// EnrichTmdbIDs looks up the TMDB IDs of the movies of a and updates the TMDB master files.
+// It returns the cached and found TMDB IDs by MAL ID. Only the movies of a are kept in memory,
+// TMDB API lookups run on a bounded worker pool.
+func (s *service) EnrichTmdbIDs(ctx context.Context, rootPath string, a iter.Seq2[domain.Anime, error], cacheRepo domain.CacheRepo) (map[int]int, error) {
+	cachedTmdbIDs := s.cachedTmdbIDs(ctx, cacheRepo)
+
+	ids := make(map[int]int)
+	movies := []domain.Anime{}
+	for anime, err := range a {
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to get anime list")
+		}
+
+		if tmdbID, found := cachedTmdbIDs[anime.MalID]; found && tmdbID > 0 {
+			anime.TmdbID = tmdbID
+			ids[anime.MalID] = tmdbID
+		}
+
+		if anime.Type == "movie" {
+			movies = append(movies, anime)
 		}
 	}
 
 	// Filter movies to fetch based on configured TMDB mode
-	toFetch := s.filterMoviesToFetch(a, cachedTmdbIDs)
+	toFetch := s.filterMoviesToFetch(movies, cachedTmdbIDs)
 
 	if len(toFetch) == 0 {
 		s.log.Info().Msg("All movies already cached, skipping TMDB lookups")
-		// Still store the updated list with cached TMDB IDs
-		if err := s.animeRepo.Store(ctx, s.paths.TMDBPath, a); err != nil {
-			return errors.Wrap(err, "failed to store TMDB IDs")
-		}
 		// Still update master files
-		return s.updateMasterFiles(ctx, rootPath, a)
+		if err := s.updateMasterFiles(ctx, rootPath, movies); err != nil {
+			return nil, err
+		}
+		return ids, nil
 	}
 
 	// Load anime-list.xml from current directory (./) instead of root-path
-	al, err := animelist.NewAnimeList(ctx, ".")
+	al, err := animelist.NewAnimeList(ctx, ".", animelist.WithOffline(s.config.Offline))
 	if err != nil {
 		s.log.Warn().Err(err).Msg("failed to load anime-list.xml, will use TMDB API only")
 		al = nil
 	}
 
 	u := s.buildUrl(s.config.TmdbApiKey)
-	am := &domain.AnimeMovies{}
 	noTmdbTotal := 0
 	withTmdbTotal := 0
 	fromAnimeListTotal := 0
 	totalMovies := 0
 
 	// Build map for O(1) MAL ID lookup
-	malIDToIndex := make(map[int]int, len(a))
-	for i := range a {
-		malIDToIndex[a[i].MalID] = i
+	malIDToIndex := make(map[int]int, len(movies))
+	for i := range movies {
+		malIDToIndex[movies[i].MalID] = i
 	}
 
-	for _, anime := range toFetch {
-		totalMovies++
-		matched := false
-
-		// First, try to get TMDB ID from anime-list.xml if we have an AniDB ID
-		if al != nil && anime.AnidbID > 0 {
-			if tmdbID := al.GetTmdbID(anime.AnidbID); tmdbID > 0 {
-				// Found in anime-list.xml
-				if i, found := malIDToIndex[anime.MalID]; found {
-					a[i].TmdbID = tmdbID
-					withTmdbTotal++
-					fromAnimeListTotal++
-					matched = true
-					s.log.Debug().
-						Str("title", anime.MainTitle).
-						Int("tmdb_id", tmdbID).
-						Int("anidb_id", anime.AnidbID).
-						Msg("TMDBID found in anime-list.xml")
-
-					// Update TMDB cache immediately when TMDB ID is found
-					if cacheRepo != nil {
-						if err := cacheRepo.UpsertTMDB(ctx, anime.MalID, tmdbID); err != nil {
-							s.log.Warn().Err(err).Int("mal_id", anime.MalID).Msg("failed to update TMDB cache")
-						} else {
-							s.log.Debug().Int("mal_id", anime.MalID).Int("tmdb_id", tmdbID).Msg("Updated TMDB cache")
-						}
-					}
-				}
-			}
-		}
+	// Cache writes must finish even when the run is cancelled, so found IDs are not lost
+	writeCtx := context.WithoutCancel(ctx)
 
-		// If not found in anime-list.xml, fall back to TMDB API
-		if !matched {
-			target := *u
-			query := target.Query()
-			if anime.EnglishTitle != "" {
-				query.Add("query", anime.EnglishTitle)
-			} else {
-				query.Add("query", anime.MainTitle)
-			}
+	jobs := make(chan domain.Anime)
+	lookups := make(chan movieLookup)
 
-			if anime.ReleaseDate == "" {
-				noTmdbTotal++
-				s.log.Debug().Str("title", anime.MainTitle).Msg("does not have a release date")
-				am.Add(anime.MainTitle, 0, anime.MalID)
-				continue
+	var wg sync.WaitGroup
+	for i := 0; i < s.config.TMDBConcurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for anime := range jobs {
+				tmdbID, provenance := s.lookupMovie(ctx, u, al, anime)
+				lookups <- movieLookup{anime: anime, tmdbID: tmdbID, provenance: provenance}
 			}
+		}()
+	}
 
-			year := s.getYear(anime.ReleaseDate)
-			query.Add("year", year)
-			target.RawQuery = query.Encode()
+	go func() {
+		defer close(lookups)
+		defer wg.Wait()
+		defer close(jobs)
 
-			tmdb, err := s.searchTMDB(ctx, target.String())
-			if err != nil {
-				s.log.Warn().Err(err).Str("title", anime.MainTitle).Msg("failed to search TMDB")
-				noTmdbTotal++
-				am.Add(anime.MainTitle, 0, anime.MalID)
-				continue
+		for _, anime := range toFetch {
+			select {
+			case <-ctx.Done():
+				return
+			case jobs <- anime:
 			}
+		}
+	}()
 
-			// Match on exact date OR single result
-			var tmdbID int
-			for _, result := range tmdb.Results {
-				if result.ReleaseDate == anime.ReleaseDate || tmdb.TotalResults == 1 {
-					tmdbID = result.ID
-					matched = true
-					s.log.Debug().Str("title", anime.MainTitle).Int("tmdb_id", result.ID).Msg("TMDBID added from API")
-					break
-				} else {
-					// Log warning for each non-matching result
-					s.log.Warn().
-						Str("title", anime.MainTitle).
-						Str("tmdb_date", result.ReleaseDate).
-						Str("mal_date", anime.ReleaseDate).
-						Int("total_results", tmdb.TotalResults).
-						Msg("TMDB date does not match MAL date and has multiple results")
-				}
-			}
+	// Results are applied by this goroutine only
+	for lookup := range lookups {
+		totalMovies++
 
-			// Update anime list and cache if matched
-			if matched && tmdbID > 0 {
-				// O(1) lookup using map
-				if i, found := malIDToIndex[anime.MalID]; found {
-					a[i].TmdbID = tmdbID
-					withTmdbTotal++
-
-					// Update TMDB cache immediately when TMDB ID is found
-					if cacheRepo != nil {
-						if err := cacheRepo.UpsertTMDB(ctx, anime.MalID, tmdbID); err != nil {
-							s.log.Warn().Err(err).Int("mal_id", anime.MalID).Msg("failed to update TMDB cache")
-						} else {
-							s.log.Debug().Int("mal_id", anime.MalID).Int("tmdb_id", tmdbID).Msg("Updated TMDB cache")
-						}
-					}
-				}
-			}
+		if lookup.tmdbID == 0 {
+			noTmdbTotal++
+			continue
+		}
+
+		// O(1) lookup using map
+		i, found := malIDToIndex[lookup.anime.MalID]
+		if !found {
+			continue
+		}
+		movies[i].TmdbID = lookup.tmdbID
+		ids[lookup.anime.MalID] = lookup.tmdbID
+		withTmdbTotal++
+		if lookup.provenance == domain.ProvenanceAnimeList {
+			fromAnimeListTotal++
+		}
 
-			if !matched {
-				noTmdbTotal++
-				am.Add(anime.MainTitle, 0, anime.MalID)
-				s.log.Warn().
-					Str("title", anime.MainTitle).
-					Int("mal_id", anime.MalID).
-					Str("english_title", anime.EnglishTitle).
-					Str("release_date", anime.ReleaseDate).
-					Msg("No TMDB ID found")
+		// Update TMDB cache immediately when TMDB ID is found
+		if cacheRepo != nil {
+			if err := cacheRepo.UpsertTMDB(writeCtx, lookup.anime.MalID, lookup.tmdbID, lookup.provenance); err != nil {
+				s.log.Warn().Err(err).Int("mal_id", lookup.anime.MalID).Msg("failed to update TMDB cache")
+			} else {
+				s.log.Debug().Int("mal_id", lookup.anime.MalID).Int("tmdb_id", lookup.tmdbID).Msg("Updated TMDB cache")
 			}
 		}
 	}
 
-	if err := s.animeRepo.Store(ctx, s.paths.TMDBPath, a); err != nil {
-		return errors.Wrap(err, "failed to store TMDB IDs")
+	if err := ctx.Err(); err != nil {
+		s.log.Warn().Int("remaining", len(toFetch)-totalMovies).Msg("TMDB lookups cancelled")
+		return nil, err
 	}
 
 	s.log.Info().
@@ -237,11 +291,100 @@ func (s *service) GetTmdbIds(ctx context.Context, rootPath string, cacheRepo dom
 		Int("without_tmdbid", noTmdbTotal).
 		Msg("TMDB ID mapping complete")
 
-	if err := s.animeRepo.Store(ctx, s.paths.TMDBPath, a); err != nil {
-		return errors.Wrap(err, "failed to store TMDB IDs")
+	if err := s.updateMasterFiles(ctx, rootPath, movies); err != nil {
+		return nil, err
 	}
 
-	return s.updateMasterFiles(ctx, rootPath, a)
+	return ids, nil
+}
+
+// RefreshTmdbID looks up the TMDB ID of a single movie live, ignoring the fetch mode, and updates the cache.
+// It returns 0 if no TMDB ID was found.
+func (s *service) RefreshTmdbID(ctx context.Context, cacheRepo domain.CacheRepo, anime domain.Anime) (int, domain.Provenance, error) {
+	al, err := animelist.NewAnimeList(ctx, ".", animelist.WithOffline(s.config.Offline))
+	if err != nil {
+		s.log.Warn().Err(err).Msg("failed to load anime-list.xml, will use TMDB API only")
+		al = nil
+	}
+
+	tmdbID, provenance := s.lookupMovie(ctx, s.buildUrl(s.config.TmdbApiKey), al, anime)
+	if err := ctx.Err(); err != nil {
+		return 0, "", err
+	}
+
+	if tmdbID > 0 && cacheRepo != nil {
+		if err := cacheRepo.UpsertTMDB(context.WithoutCancel(ctx), anime.MalID, tmdbID, provenance); err != nil {
+			return 0, "", errors.Wrap(err, "failed to update TMDB cache")
+		}
+	}
+
+	return tmdbID, provenance, nil
+}
+
+// lookupMovie finds the TMDB ID of a movie, first in anime-list.xml and then through the TMDB search API.
+// It returns 0 if no TMDB ID was found.
+func (s *service) lookupMovie(ctx context.Context, u *url.URL, al *animelist.AnimeList, anime domain.Anime) (int, domain.Provenance) {
+	// First, try to get TMDB ID from anime-list.xml if we have an AniDB ID
+	if al != nil && anime.AnidbID > 0 {
+		if tmdbID := al.GetTmdbID(anime.AnidbID); tmdbID > 0 {
+			s.log.Debug().
+				Str("title", anime.MainTitle).
+				Int("tmdb_id", tmdbID).
+				Int("anidb_id", anime.AnidbID).
+				Msg("TMDBID found in anime-list.xml")
+			return tmdbID, domain.ProvenanceAnimeList
+		}
+	}
+
+	// If not found in anime-list.xml, fall back to TMDB API
+	target := *u
+	query := target.Query()
+	if anime.EnglishTitle != "" {
+		query.Add("query", anime.EnglishTitle)
+	} else {
+		query.Add("query", anime.MainTitle)
+	}
+
+	if anime.ReleaseDate == "" {
+		s.log.Debug().Str("title", anime.MainTitle).Msg("does not have a release date")
+		return 0, ""
+	}
+
+	year := s.getYear(anime.ReleaseDate)
+	query.Add("year", year)
+	target.RawQuery = query.Encode()
+
+	tmdb, err := s.searchTMDB(ctx, target.String())
+	if err != nil {
+		if ctx.Err() == nil {
+			s.log.Warn().Err(err).Str("title", anime.MainTitle).Msg("failed to search TMDB")
+		}
+		return 0, ""
+	}
+
+	// Match on exact date OR single result
+	for _, result := range tmdb.Results {
+		if result.ReleaseDate == anime.ReleaseDate || tmdb.TotalResults == 1 {
+			s.log.Debug().Str("title", anime.MainTitle).Int("tmdb_id", result.ID).Msg("TMDBID added from API")
+			return result.ID, domain.ProvenanceTMDBSearch
+		}
+
+		// Log warning for each non-matching result
+		s.log.Warn().
+			Str("title", anime.MainTitle).
+			Str("tmdb_date", result.ReleaseDate).
+			Str("mal_date", anime.ReleaseDate).
+			Int("total_results", tmdb.TotalResults).
+			Msg("TMDB date does not match MAL date and has multiple results")
+	}
+
+	s.log.Warn().
+		Str("title", anime.MainTitle).
+		Int("mal_id", anime.MalID).
+		Str("english_title", anime.EnglishTitle).
+		Str("release_date", anime.ReleaseDate).
+		Msg("No TMDB ID found")
+	return 0, ""
 }
 
 // filterMoviesToFetch filters movies based on configured TMDB mode
@@ -290,7 +433,7 @@ func (s *service) filterMoviesToFetch(animeList []domain.Anime, cachedTmdbIDs ma
 	return toFetch
 }
 
-// updateMasterFiles updates the TMDB master mapping files
+// updateMasterFiles updates the TMDB master mapping files from the movies of animeList
 func (s *service) updateMasterFiles(ctx context.Context, rootPath string, animeList []domain.Anime) error {
 	am := &domain.AnimeMovies{}
 	for _, anime := range animeList {
@@ -317,17 +460,43 @@ func (s *service) updateMasterFiles(ctx context.Context, rootPath string, animeL
 	return nil
 }
 
+// searchTMDB runs a TMDB search request. Requests are paced by the shared token bucket
+// and retried after the Retry-After delay when TMDB still answers 429 Too Many Requests.
 func (s *service) searchTMDB(ctx context.Context, url string) (*TMDBAPIResponse, error) {
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
-	if err != nil {
-		return nil, errors.Wrap(err, "failed to create request")
-	}
+	for attempt := 0; ; attempt++ {
+		if err := s.limiter.Wait(ctx); err != nil {
+			return nil, errors.Wrap(err, "rate limiter")
+		}
 
-	client := &http.Client{}
-	resp, err := client.Do(req)
-	if err != nil {
-		return nil, errors.Wrap(err, "failed to fetch")
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to create request")
+		}
+
+		resp, err := s.client.Do(req)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to fetch")
+		}
+
+		if resp.StatusCode == http.StatusTooManyRequests && attempt < maxRateLimitRetries {
+			resp.Body.Close()
+
+			wait := retryAfter(resp.Header.Get("Retry-After"))
+			s.log.Warn().Dur("retry_after", wait).Msg("TMDB rate limit hit, backing off")
+
+			select {
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			case <-time.After(wait):
+			}
+			continue
+		}
+
+		return decodeSearchResponse(resp)
 	}
+}
+
+func decodeSearchResponse(resp *http.Response) (*TMDBAPIResponse, error) {
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
@@ -348,6 +517,16 @@ func (s *service) searchTMDB(ctx context.Context, url string) (*TMDBAPIResponse,
 	return tmdb, nil
 }
 
+// retryAfter parses a Retry-After header in seconds, defaulting to one second
+func retryAfter(header string) time.Duration {
+	seconds, err := strconv.Atoi(header)
+	if err != nil || seconds <= 0 {
+		return time.Second
+	}
+
+	return time.Duration(seconds) * time.Second
+}
+
 func (s *service) buildUrl(apikey string) *url.URL {
 	baseUrl := "https://api.themoviedb.org/3/search/movie"
 	u, err := url.Parse(baseUrl)
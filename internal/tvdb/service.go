@@ -2,6 +2,7 @@ package tvdb
 
 import (
 	"context"
+	"iter"
 	"os"
 	"path/filepath"
 	"strings"
@@ -13,71 +14,47 @@ import (
 )
 
 type Service interface {
-	GetTvdbIDs(ctx context.Context, rootPath string) error
+	EnrichTvdbIDs(ctx context.Context, rootPath string, anime iter.Seq2[domain.Anime, error]) (map[int]int, error)
 }
 
 type service struct {
-	log        zerolog.Logger
-	animeRepo  domain.AnimeRepository
+	log         zerolog.Logger
+	config      *domain.Config
 	mappingRepo domain.MappingRepository
-	paths      *domain.Paths
 }
 
-func NewService(log zerolog.Logger, animeRepo domain.AnimeRepository, mappingRepo domain.MappingRepository, paths *domain.Paths) Service {
+// NewService creates a TVDB service that looks up TVDB IDs and maintains the TVDB mapping files
+func NewService(log zerolog.Logger, config *domain.Config, mappingRepo domain.MappingRepository) Service {
 	return &service{
-		log:        log.With().Str("module", "tvdb").Logger(),
-		animeRepo:  animeRepo,
+		log:         log.With().Str("module", "tvdb").Logger(),
+		config:      config,
 		mappingRepo: mappingRepo,
-		paths:      paths,
 	}
 }
 
-func (s *service) GetTvdbIDs(ctx context.Context, rootPath string) error {
+// EnrichTvdbIDs looks up the TVDB IDs of the TV entries of a and updates the TVDB master files.
+// It returns the TVDB IDs found by MAL ID.
+func (s *service) EnrichTvdbIDs(ctx context.Context, rootPath string, a iter.Seq2[domain.Anime, error]) (map[int]int, error) {
 	// Store anime-list.xml in current directory (./) instead of root-path
-	al, err := animelist.NewAnimeList(ctx, ".")
+	al, err := animelist.NewAnimeList(ctx, ".", animelist.WithOffline(s.config.Offline))
 	if err != nil {
-		return errors.Wrap(err, "failed to create anime list")
+		return nil, errors.Wrap(err, "failed to create anime list")
 	}
 
-	a, err := s.animeRepo.Get(ctx, s.paths.AniDBPath)
-	if err != nil {
-		return errors.Wrap(err, "failed to get anime list")
-	}
+	// The unmapped TVDB map lists every entry, it is built while the entries are streamed
+	ids := make(map[int]int)
+	unmapped := &domain.TVDBMap{}
+	for anime, err := range a {
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to get anime list")
+		}
 
-	updated := 0
-	for i, anime := range a {
 		if anime.Type == "tv" && anime.AnidbID > 0 {
 			if tvdbid := al.GetTvdbID(anime.AnidbID); tvdbid > 0 {
-				a[i].TvdbID = tvdbid
-				updated++
+				ids[anime.MalID] = tvdbid
 			}
 		}
-	}
-
-	if err := s.animeRepo.Store(ctx, s.paths.TVDBPath, a); err != nil {
-		return errors.Wrap(err, "failed to store TVDB IDs")
-	}
 
-	s.log.Info().Int("updated_count", updated).Msg("TVDB ID mapping complete")
-
-	// Create and update TVDB mapping master (similar to TMDB)
-	if err := s.createAndUpdateMaster(ctx, rootPath); err != nil {
-		return errors.Wrap(err, "failed to create and update TVDB mapping")
-	}
-
-	return nil
-}
-
-func (s *service) createAndUpdateMaster(ctx context.Context, rootPath string) error {
-	// Get anime data for creating unmapped map
-	animeList, err := s.animeRepo.Get(ctx, s.paths.MalIDPath)
-	if err != nil {
-		return errors.Wrap(err, "failed to get anime list for mapping")
-	}
-
-	// Create unmapped TVDB map from anime data
-	unmapped := &domain.TVDBMap{}
-	for _, anime := range animeList {
 		unmapped.Anime = append(unmapped.Anime, domain.TVDBAnime{
 			Malid:        anime.MalID,
 			Title:        anime.MainTitle,
@@ -90,6 +67,17 @@ func (s *service) createAndUpdateMaster(ctx context.Context, rootPath string) er
 		})
 	}
 
+	s.log.Info().Int("updated_count", len(ids)).Msg("TVDB ID mapping complete")
+
+	// Create and update TVDB mapping master (similar to TMDB)
+	if err := s.createAndUpdateMaster(ctx, rootPath, unmapped); err != nil {
+		return nil, errors.Wrap(err, "failed to create and update TVDB mapping")
+	}
+
+	return ids, nil
+}
+
+func (s *service) createAndUpdateMaster(ctx context.Context, rootPath string, unmapped *domain.TVDBMap) error {
 	// Store unmapped file
 	unmappedPath := filepath.Join(rootPath, "tvdb-mal-unmapped.yaml")
 	if err := s.mappingRepo.StoreTVDBMaster(ctx, unmappedPath, unmapped); err != nil {
@@ -133,4 +121,3 @@ func (s *service) createAndUpdateMaster(ctx context.Context, rootPath string) er
 	s.log.Info().Msg("TVDB mapping master updated")
 	return nil
 }
-
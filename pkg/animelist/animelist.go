@@ -1,21 +1,32 @@
+// Package animelist reads anime-list.xml of the Anime-Lists project, which maps AniDB IDs to
+// TVDB, TMDB and IMDb IDs, and answers lookups in both directions.
 package animelist
 
 import (
+	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/xml"
 	"fmt"
 	"io"
 	"net/http"
-	"os"
 	"path/filepath"
-	"strconv"
+	"slices"
+	"strings"
 	"time"
+
+	"github.com/varoOP/shinkrodb/pkg/remotefile"
 )
 
+// AnimeList holds the entries of anime-list.xml with indexes for O(1) lookups
 type AnimeList struct {
 	XMLName xml.Name `xml:"anime-list"`
 	Text    string   `xml:",chardata"`
-	Anime   []struct {
+	// Anime holds the raw anime elements, including those without a valid AniDB ID.
+	//
+	// Deprecated: Use Entries, GetEntry and the lookup methods, which return typed entries.
+	Anime []struct {
 		Text              string `xml:",chardata"`
 		Anidbid           string `xml:"anidbid,attr"`
 		Tvdbid            string `xml:"tvdbid,attr"`
@@ -28,174 +39,273 @@ type AnimeList struct {
 		} `xml:"supplemental-info"`
 	} `xml:"anime"`
 
+	entries []Entry
+
 	// Cache for O(1) lookups
-	tvdbMap map[int]int
-	tmdbMap map[int]int
+	entryIndex map[int]int      // AniDB ID -> index in entries
+	nameMap    map[string][]int // Normalised name -> AniDB IDs
+	tvdbAnidb  map[int][]int    // TVDB ID -> AniDB IDs
+	tmdbAnidb  map[int][]int    // TMDB ID -> AniDB IDs
+
+	// SHA-256 of the XML document the list was parsed from
+	sha256 string
 }
 
 const (
-	animeListURL  = "https://raw.githubusercontent.com/Anime-Lists/anime-lists/master/anime-list.xml"
+	// DefaultURL is where anime-list.xml is downloaded from unless WithURL is used
+	DefaultURL    = "https://raw.githubusercontent.com/Anime-Lists/anime-lists/master/anime-list.xml"
 	cacheFileName = "anime-list.xml"
-	cacheMaxAge   = 24 * time.Hour // Refresh cache every 24 hours
+	cacheMaxAge   = 24 * time.Hour // Revalidate cache every 24 hours
 )
 
-// NewAnimeList creates a new AnimeList with caching support
-// cacheDir: directory to cache the XML file (empty string disables caching)
-func NewAnimeList(ctx context.Context, cacheDir string) (*AnimeList, error) {
-	al := &AnimeList{
-		tvdbMap: make(map[int]int),
-		tmdbMap: make(map[int]int),
-	}
+// Option configures how NewAnimeList gets anime-list.xml
+type Option func(*options)
 
-	var body []byte
-	var err error
+type options struct {
+	offline bool
+	url     string
+	client  *http.Client
+	onStale func(err error)
+}
 
-	// Try to load from cache if cache directory is provided
-	if cacheDir != "" {
-		cachePath := filepath.Join(cacheDir, cacheFileName)
-		if body, err = loadFromCache(ctx, cachePath); err == nil {
-			// Successfully loaded from cache
-		} else {
-			// Cache miss or error, fetch from URL
-			body, err = fetchFromURL(ctx, cachePath)
-			if err != nil {
-				return nil, err
-			}
-		}
-	} else {
-		// No caching, fetch directly
-		body, err = fetchDirectly(ctx)
-		if err != nil {
-			return nil, err
-		}
+// WithOffline only uses the cached anime-list.xml, whatever its age, and fails if there is none
+func WithOffline(offline bool) Option {
+	return func(o *options) {
+		o.offline = offline
 	}
+}
 
-	// Parse XML
-	err = xml.Unmarshal(body, al)
-	if err != nil {
-		return nil, fmt.Errorf("failed to parse XML: %w", err)
+// WithURL downloads anime-list.xml from url instead of DefaultURL, e.g. a mirror or a fork
+func WithURL(url string) Option {
+	return func(o *options) {
+		o.url = url
 	}
+}
 
-	// Build lookup map for O(1) access
-	al.buildMap()
-
-	return al, nil
+// WithHTTPClient downloads anime-list.xml with client instead of a client with a 30 second timeout
+func WithHTTPClient(client *http.Client) Option {
+	return func(o *options) {
+		o.client = client
+	}
 }
 
-// loadFromCache attempts to load the XML file from cache
-func loadFromCache(ctx context.Context, cachePath string) ([]byte, error) {
-	// Check if context is cancelled
-	if err := ctx.Err(); err != nil {
-		return nil, err
+// WithOnStale calls fn with the download error when a stale cached anime-list.xml is used instead
+func WithOnStale(fn func(err error)) Option {
+	return func(o *options) {
+		o.onStale = fn
 	}
+}
 
-	info, err := os.Stat(cachePath)
-	if err != nil {
-		return nil, err // Cache file doesn't exist
+// NewAnimeList creates a new AnimeList with caching support
+// cacheDir: directory to cache the XML file (empty string disables caching)
+func NewAnimeList(ctx context.Context, cacheDir string, opts ...Option) (*AnimeList, error) {
+	o := &options{url: DefaultURL}
+	for _, opt := range opts {
+		opt(o)
 	}
 
-	// Check if cache is still fresh
-	if time.Since(info.ModTime()) > cacheMaxAge {
-		return nil, fmt.Errorf("cache expired")
+	file := &remotefile.File{
+		URL:     o.url,
+		MaxAge:  cacheMaxAge,
+		Offline: o.offline,
+		Client:  o.client,
+		OnStale: o.onStale,
+	}
+	if cacheDir != "" {
+		file.Path = filepath.Join(cacheDir, cacheFileName)
 	}
 
-	body, err := os.ReadFile(cachePath)
+	body, err := file.Get(ctx)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read cache file: %w", err)
+		return nil, fmt.Errorf("failed to get anime list: %w", err)
 	}
 
-	return body, nil
+	return Parse(bytes.NewReader(body))
 }
 
-// fetchFromURL fetches the XML from URL and saves to cache
-func fetchFromURL(ctx context.Context, cachePath string) ([]byte, error) {
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, animeListURL, nil)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
-	}
+// Parse reads an anime-list.xml document from r, e.g. a local copy or a test fixture
+func Parse(r io.Reader) (*AnimeList, error) {
+	// The document is hashed while it is decoded
+	hash := sha256.New()
 
-	client := &http.Client{
-		Timeout: 30 * time.Second,
-	}
-	resp, err := client.Do(req)
-	if err != nil {
-		return nil, fmt.Errorf("failed to fetch anime list: %w", err)
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	doc := &xmlAnimeList{}
+	if err := xml.NewDecoder(io.TeeReader(r, hash)).Decode(doc); err != nil {
+		return nil, fmt.Errorf("failed to parse XML: %w", err)
 	}
 
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read response: %w", err)
+	// Hash whatever follows the root element, so the sum covers the whole document
+	if _, err := io.Copy(hash, r); err != nil {
+		return nil, fmt.Errorf("failed to read XML: %w", err)
 	}
 
-	// Save to cache (best effort, don't fail if cache write fails)
-	if err := os.MkdirAll(filepath.Dir(cachePath), 0755); err == nil {
-		_ = os.WriteFile(cachePath, body, 0644)
+	al := &AnimeList{
+		entries:    make([]Entry, 0, len(doc.Anime)),
+		entryIndex: make(map[int]int, len(doc.Anime)),
+		nameMap:    make(map[string][]int),
+		tvdbAnidb:  make(map[int][]int),
+		tmdbAnidb:  make(map[int][]int),
+		sha256:     hex.EncodeToString(hash.Sum(nil)),
 	}
 
-	return body, nil
-}
+	al.XMLName = doc.XMLName
+	al.Text = doc.Text
 
-// fetchDirectly fetches the XML from URL without caching
-func fetchDirectly(ctx context.Context) ([]byte, error) {
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, animeListURL, nil)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
+	// Keep filling the deprecated raw elements for callers that still read them
+	al.Anime = slices.Grow(al.Anime, len(doc.Anime))[:len(doc.Anime)]
+	for i, x := range doc.Anime {
+		raw := &al.Anime[i]
+		raw.Text = x.Text
+		raw.Anidbid = x.Anidbid
+		raw.Tvdbid = x.Tvdbid
+		raw.Tmdbid = x.Tmdbid
+		raw.Defaulttvdbseason = x.Defaulttvdbseason
+		raw.Name = x.Name
+		raw.SupplementalInfo.Text = x.SupplementalInfo.Text
+		raw.SupplementalInfo.Studio = x.SupplementalInfo.Studio
 	}
 
-	client := &http.Client{
-		Timeout: 30 * time.Second,
-	}
-	resp, err := client.Do(req)
-	if err != nil {
-		return nil, fmt.Errorf("failed to fetch anime list: %w", err)
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	for i := range doc.Anime {
+		entry, ok := doc.Anime[i].entry()
+		if !ok {
+			continue // Skip invalid AniDB IDs
+		}
+		al.entries = append(al.entries, entry)
 	}
 
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read response: %w", err)
-	}
+	// Build lookup map for O(1) access
+	al.buildMap()
 
-	return body, nil
+	return al, nil
 }
 
 // buildMap builds an in-memory map for O(1) lookups
 func (a *AnimeList) buildMap() {
-	for _, anime := range a.Anime {
-		anidbID, err := strconv.Atoi(anime.Anidbid)
-		if err != nil {
-			continue // Skip invalid AniDB IDs
+	for i, entry := range a.entries {
+		a.entryIndex[entry.AnidbID] = i
+
+		if entry.TvdbID > 0 {
+			a.tvdbAnidb[entry.TvdbID] = append(a.tvdbAnidb[entry.TvdbID], entry.AnidbID)
 		}
 
-		// Build TVDB map
-		tvdbID, err := strconv.Atoi(anime.Tvdbid)
-		if err == nil && tvdbID > 0 {
-			a.tvdbMap[anidbID] = tvdbID
+		if entry.TmdbID > 0 {
+			a.tmdbAnidb[entry.TmdbID] = append(a.tmdbAnidb[entry.TmdbID], entry.AnidbID)
 		}
 
-		// Build TMDB map
-		tmdbID, err := strconv.Atoi(anime.Tmdbid)
-		if err == nil && tmdbID > 0 {
-			a.tmdbMap[anidbID] = tmdbID
+		if name := normalizeName(entry.Name); name != "" {
+			a.nameMap[name] = append(a.nameMap[name], entry.AnidbID)
 		}
 	}
 }
 
+// normalizeName normalizes a title for case-insensitive name lookups
+func normalizeName(name string) string {
+	return strings.ToLower(strings.TrimSpace(name))
+}
+
+// Len returns the number of entries
+func (a *AnimeList) Len() int {
+	return len(a.entries)
+}
+
+// Entries returns all entries in document order. The slice must not be modified.
+func (a *AnimeList) Entries() []Entry {
+	return a.entries
+}
+
+// GetEntry returns the entry for a given AniDB ID (O(1) lookup)
+func (a *AnimeList) GetEntry(aid int) (Entry, bool) {
+	if i, ok := a.entryIndex[aid]; ok {
+		return a.entries[i], true
+	}
+	return Entry{}, false
+}
+
 // GetTvdbID returns the TVDB ID for a given AniDB ID (O(1) lookup)
 func (a *AnimeList) GetTvdbID(aid int) int {
-	return a.tvdbMap[aid]
+	entry, _ := a.GetEntry(aid)
+	return entry.TvdbID
 }
 
 // GetTmdbID returns the TMDB ID for a given AniDB ID (O(1) lookup)
 func (a *AnimeList) GetTmdbID(aid int) int {
-	return a.tmdbMap[aid]
+	entry, _ := a.GetEntry(aid)
+	return entry.TmdbID
+}
+
+// GetName returns the name of the entry for a given AniDB ID (O(1) lookup)
+func (a *AnimeList) GetName(aid int) string {
+	entry, _ := a.GetEntry(aid)
+	return entry.Name
+}
+
+// GetDefaultTvdbSeason returns the default TVDB season of the entry for a given AniDB ID (O(1) lookup).
+// The value is a season number or "a" for absolute episode numbering.
+func (a *AnimeList) GetDefaultTvdbSeason(aid int) string {
+	entry, _ := a.GetEntry(aid)
+	return entry.DefaultTvdbSeason
+}
+
+// GetMappingList returns the episode mappings of the entry for a given AniDB ID (O(1) lookup)
+func (a *AnimeList) GetMappingList(aid int) []Mapping {
+	entry, _ := a.GetEntry(aid)
+	return entry.MappingList
+}
+
+// GetAnidbIDsByName returns the AniDB IDs whose name matches the given title case-insensitively (O(1) lookup)
+func (a *AnimeList) GetAnidbIDsByName(name string) []int {
+	return a.nameMap[normalizeName(name)]
+}
+
+// SearchByName returns the entries whose name contains query case-insensitively, in document order
+func (a *AnimeList) SearchByName(query string) []Entry {
+	query = normalizeName(query)
+	if query == "" {
+		return nil
+	}
+
+	found := []Entry{}
+	for _, entry := range a.entries {
+		if strings.Contains(normalizeName(entry.Name), query) {
+			found = append(found, entry)
+		}
+	}
+
+	return found
+}
+
+// GetAnidbIDsByTvdbID returns the AniDB IDs mapped to a given TVDB ID (O(1) lookup)
+func (a *AnimeList) GetAnidbIDsByTvdbID(tvdbID int) []int {
+	return a.tvdbAnidb[tvdbID]
+}
+
+// GetAnidbIDsByTmdbID returns the AniDB IDs mapped to a given TMDB ID (O(1) lookup)
+func (a *AnimeList) GetAnidbIDsByTmdbID(tmdbID int) []int {
+	return a.tmdbAnidb[tmdbID]
+}
+
+// GetEntriesByTvdbID returns the entries mapped to a given TVDB ID, e.g. one per season (O(1) lookup)
+func (a *AnimeList) GetEntriesByTvdbID(tvdbID int) []Entry {
+	return a.entriesOf(a.tvdbAnidb[tvdbID])
+}
+
+// GetEntriesByTmdbID returns the entries mapped to a given TMDB ID (O(1) lookup)
+func (a *AnimeList) GetEntriesByTmdbID(tmdbID int) []Entry {
+	return a.entriesOf(a.tmdbAnidb[tmdbID])
+}
+
+// entriesOf returns the entries of the AniDB IDs
+func (a *AnimeList) entriesOf(aids []int) []Entry {
+	entries := make([]Entry, 0, len(aids))
+	for _, aid := range aids {
+		if entry, ok := a.GetEntry(aid); ok {
+			entries = append(entries, entry)
+		}
+	}
+
+	return entries
+}
+
+// SHA256 returns the hex encoded SHA-256 of the anime-list.xml document the list was parsed from
+func (a *AnimeList) SHA256() string {
+	return a.sha256
 }
@@ -0,0 +1,148 @@
+package animelist
+
+import (
+	"encoding/xml"
+	"strconv"
+	"strings"
+)
+
+// Entry is an anime of anime-list.xml, mapping an AniDB ID to TVDB, TMDB and IMDb
+type Entry struct {
+	AnidbID int
+	// TvdbID is 0 if the entry has no numeric TVDB ID, TvdbKind then holds the tvdbid value
+	// used instead, e.g. "movie", "OVA", "web", "hentai", "music video" or "unknown"
+	TvdbID   int
+	TvdbKind string
+	// DefaultTvdbSeason is a season number or "a" for absolute episode numbering
+	DefaultTvdbSeason string
+	// EpisodeOffset is added to AniDB episode numbers in the default season
+	EpisodeOffset int
+	TmdbID        int
+	// ImdbID is the raw imdbid value, it may list several IDs separated by commas
+	ImdbID string
+	Name   string
+	// MappingList maps AniDB episodes to TVDB seasons and episodes outside the default season
+	MappingList      []Mapping
+	SupplementalInfo SupplementalInfo
+}
+
+// Mapping is an entry of a mapping-list, mapping the episodes of an AniDB season to a TVDB season.
+// Episodes are mapped by Offset within Start and End, or one by one as listed in Text.
+type Mapping struct {
+	// AnidbSeason is 1 for regular episodes and 0 for specials
+	AnidbSeason int
+	TvdbSeason  int
+	Start       int
+	End         int
+	Offset      int
+	// Text is the raw episode list, e.g. ";1-5;2-6;3-0;"
+	Text string
+}
+
+// Episodes parses Text and returns the TVDB episodes of each listed AniDB episode.
+// An AniDB episode can map to several TVDB episodes ("1-1+2"), 0 means it is not on TVDB.
+func (m Mapping) Episodes() map[int][]int {
+	episodes := make(map[int][]int)
+	for _, pair := range strings.Split(m.Text, ";") {
+		anidb, tvdb, ok := strings.Cut(strings.TrimSpace(pair), "-")
+		if !ok {
+			continue
+		}
+
+		anidbEpisode, err := strconv.Atoi(anidb)
+		if err != nil {
+			continue // Skip malformed pairs
+		}
+
+		for _, value := range strings.Split(tvdb, "+") {
+			if tvdbEpisode, err := strconv.Atoi(value); err == nil {
+				episodes[anidbEpisode] = append(episodes[anidbEpisode], tvdbEpisode)
+			}
+		}
+	}
+
+	return episodes
+}
+
+// SupplementalInfo holds the supplemental-info of an entry
+type SupplementalInfo struct {
+	Studio string
+}
+
+// xmlAnimeList is the anime-list.xml document
+type xmlAnimeList struct {
+	XMLName xml.Name   `xml:"anime-list"`
+	Text    string     `xml:",chardata"`
+	Anime   []xmlAnime `xml:"anime"`
+}
+
+type xmlAnime struct {
+	Text              string `xml:",chardata"`
+	Anidbid           string `xml:"anidbid,attr"`
+	Tvdbid            string `xml:"tvdbid,attr"`
+	Defaulttvdbseason string `xml:"defaulttvdbseason,attr"`
+	Episodeoffset     string `xml:"episodeoffset,attr"`
+	Tmdbid            string `xml:"tmdbid,attr"`
+	Imdbid            string `xml:"imdbid,attr"`
+	Name              string `xml:"name"`
+	MappingList       struct {
+		Mapping []struct {
+			Text        string `xml:",chardata"`
+			Anidbseason string `xml:"anidbseason,attr"`
+			Tvdbseason  string `xml:"tvdbseason,attr"`
+			Start       string `xml:"start,attr"`
+			End         string `xml:"end,attr"`
+			Offset      string `xml:"offset,attr"`
+		} `xml:"mapping"`
+	} `xml:"mapping-list"`
+	SupplementalInfo struct {
+		Text   string `xml:",chardata"`
+		Studio string `xml:"studio"`
+	} `xml:"supplemental-info"`
+}
+
+// entry converts the XML element to an Entry, false if it has no valid AniDB ID
+func (x *xmlAnime) entry() (Entry, bool) {
+	anidbID, err := strconv.Atoi(x.Anidbid)
+	if err != nil {
+		return Entry{}, false
+	}
+
+	e := Entry{
+		AnidbID:           anidbID,
+		DefaultTvdbSeason: x.Defaulttvdbseason,
+		EpisodeOffset:     atoi(x.Episodeoffset),
+		ImdbID:            x.Imdbid,
+		Name:              x.Name,
+		SupplementalInfo:  SupplementalInfo{Studio: x.SupplementalInfo.Studio},
+	}
+
+	if tvdbID, err := strconv.Atoi(x.Tvdbid); err == nil && tvdbID > 0 {
+		e.TvdbID = tvdbID
+	} else {
+		e.TvdbKind = x.Tvdbid
+	}
+
+	if tmdbID, err := strconv.Atoi(x.Tmdbid); err == nil && tmdbID > 0 {
+		e.TmdbID = tmdbID
+	}
+
+	for _, m := range x.MappingList.Mapping {
+		e.MappingList = append(e.MappingList, Mapping{
+			AnidbSeason: atoi(m.Anidbseason),
+			TvdbSeason:  atoi(m.Tvdbseason),
+			Start:       atoi(m.Start),
+			End:         atoi(m.End),
+			Offset:      atoi(m.Offset),
+			Text:        m.Text,
+		})
+	}
+
+	return e, true
+}
+
+// atoi parses an optional numeric attribute, 0 if it is missing or not a number
+func atoi(s string) int {
+	n, _ := strconv.Atoi(s)
+	return n
+}
@@ -0,0 +1,244 @@
+// Package remotefile downloads remote files and keeps a cached copy on disk,
+// revalidated with a conditional request once it is older than a maximum age.
+package remotefile
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// ErrNotCached is returned in offline mode when the file has no cached copy
+var ErrNotCached = errors.New("no cached copy")
+
+// DefaultTimeout is the request timeout of the client used when File.Client is nil
+const DefaultTimeout = 30 * time.Second
+
+// File is a remote file cached at Path
+type File struct {
+	// URL the file is downloaded from
+	URL string
+	// Path of the cached copy, empty disables caching
+	Path string
+	// MaxAge is how long the cached copy is used before it is revalidated
+	MaxAge time.Duration
+	// Offline only uses the cached copy, whatever its age, and never downloads the file
+	Offline bool
+	// Client downloads the file, a client with DefaultTimeout if nil
+	Client *http.Client
+	// OnStale is called with the download error when the stale cached copy is used instead, if set
+	OnStale func(err error)
+}
+
+// Get returns the content of the file, from the cache while it is fresh.
+// A stale copy is revalidated with a conditional request using the ETag and Last-Modified
+// stored next to it, and still used if the download fails. A downloaded file is written to
+// the cache, failing to do so is not an error.
+func (f *File) Get(ctx context.Context) ([]byte, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	if f.Offline {
+		if f.Path == "" {
+			return nil, fmt.Errorf("%s cannot be used offline without a cache path", f.URL)
+		}
+
+		body, err := os.ReadFile(f.Path)
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil, fmt.Errorf("offline mode: %s: %w, run once without --offline to download %s", f.Path, ErrNotCached, f.URL)
+			}
+			return nil, fmt.Errorf("failed to read cache file: %w", err)
+		}
+
+		return body, nil
+	}
+
+	if f.Path == "" {
+		body, _, _, err := f.download(ctx, nil)
+		return body, err
+	}
+
+	cached, fresh := f.readCache()
+	if fresh {
+		return cached, nil
+	}
+
+	// Only a cached copy can be revalidated
+	var validators *cacheMeta
+	if cached != nil {
+		validators = f.readMeta()
+	}
+
+	body, meta, notModified, err := f.download(ctx, validators)
+	if err != nil {
+		if cached == nil || ctx.Err() != nil {
+			return nil, err
+		}
+		if f.OnStale != nil {
+			f.OnStale(err)
+		}
+		return cached, nil
+	}
+
+	if notModified {
+		// The cached copy is fresh again
+		now := time.Now()
+		_ = os.Chtimes(f.Path, now, now)
+		return cached, nil
+	}
+
+	// Best effort, the file was downloaded anyway
+	if err := os.MkdirAll(filepath.Dir(f.Path), 0755); err == nil {
+		if err := writeCache(f.Path, body); err == nil {
+			f.writeMeta(meta)
+		}
+	}
+
+	return body, nil
+}
+
+// readCache reads the cached copy and reports whether it is not older than MaxAge.
+// It returns nil if there is no readable cached copy.
+func (f *File) readCache() ([]byte, bool) {
+	info, err := os.Stat(f.Path)
+	if err != nil || info.IsDir() {
+		return nil, false
+	}
+
+	body, err := os.ReadFile(f.Path)
+	if err != nil {
+		return nil, false
+	}
+
+	return body, time.Since(info.ModTime()) <= f.MaxAge
+}
+
+// cacheMeta holds the validators of the cached copy, stored next to it as JSON
+type cacheMeta struct {
+	ETag         string `json:"etag,omitempty"`
+	LastModified string `json:"lastModified,omitempty"`
+}
+
+// metaPath returns the path of the validators of the cached copy
+func (f *File) metaPath() string {
+	return f.Path + ".meta.json"
+}
+
+// readMeta reads the validators of the cached copy, nil if there are none
+func (f *File) readMeta() *cacheMeta {
+	body, err := os.ReadFile(f.metaPath())
+	if err != nil {
+		return nil
+	}
+
+	meta := &cacheMeta{}
+	if err := json.Unmarshal(body, meta); err != nil {
+		return nil
+	}
+
+	return meta
+}
+
+// writeMeta stores the validators of a downloaded copy, removing stale ones if the server sent none
+func (f *File) writeMeta(meta cacheMeta) {
+	if meta == (cacheMeta{}) {
+		_ = os.Remove(f.metaPath())
+		return
+	}
+
+	body, err := json.Marshal(meta)
+	if err != nil {
+		return
+	}
+
+	_ = writeCache(f.metaPath(), body)
+}
+
+// download fetches the file from URL. With validators the request is conditional,
+// notModified reports that the cached copy is still current.
+func (f *File) download(ctx context.Context, validators *cacheMeta) ([]byte, cacheMeta, bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, f.URL, nil)
+	if err != nil {
+		return nil, cacheMeta{}, false, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	if validators != nil {
+		if validators.ETag != "" {
+			req.Header.Set("If-None-Match", validators.ETag)
+		}
+		if validators.LastModified != "" {
+			req.Header.Set("If-Modified-Since", validators.LastModified)
+		}
+	}
+
+	client := f.Client
+	if client == nil {
+		client = &http.Client{Timeout: DefaultTimeout}
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, cacheMeta{}, false, fmt.Errorf("failed to fetch %s: %w", f.URL, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotModified && validators != nil {
+		return nil, cacheMeta{}, true, nil
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, cacheMeta{}, false, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, cacheMeta{}, false, fmt.Errorf("failed to read response: %w", err)
+	}
+
+	meta := cacheMeta{
+		ETag:         resp.Header.Get("ETag"),
+		LastModified: resp.Header.Get("Last-Modified"),
+	}
+
+	return body, meta, false, nil
+}
+
+// writeCache writes the cache file through a temporary file and a rename,
+// so concurrent readers never see a partially written file
+func writeCache(path string, body []byte) error {
+	f, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+
+	if _, err := f.Write(body); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return err
+	}
+
+	if err := f.Close(); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+
+	if err := os.Chmod(f.Name(), 0644); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+
+	if err := os.Rename(f.Name(), path); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+
+	return nil
+}